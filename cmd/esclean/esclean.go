@@ -1,13 +1,21 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"os"
+	"os/signal"
+	"path"
 	"path/filepath"
+	"runtime"
+	"sort"
 	"strings"
+	"time"
 
 	"github.com/mkock/esclean/engine"
 	"github.com/mkock/esclean/engine/loaders"
+	"github.com/mkock/esclean/script"
 )
 
 // Exit codes.
@@ -16,39 +24,405 @@ const (
 	ExitDirErr
 	ExitFileErr
 	ExitParserErr
+	ExitCancelled
 )
 
 func main() {
-	if len(os.Args) != 2 || (!strings.HasSuffix(os.Args[1], ".js") && !strings.HasSuffix(os.Args[1], ".ts")) {
-		fmt.Println("Missing: name of index.js or index.ts file")
-		os.Exit(ExitMissArgs)
+	defer cleanup()
+
+	// esclean watch [flags] index.ts keeps analysing the project as it changes.
+	watchMode := len(os.Args) > 1 && os.Args[1] == "watch"
+	if watchMode {
+		os.Args = append(os.Args[:1], os.Args[2:]...)
 	}
-	fix := os.Args[1]
 
-	// Resolve fix to an absolute path.
-	if !filepath.IsAbs(fix) {
-		cwd, err := os.Getwd()
-		if err != nil {
-			fmt.Println("Unable to determine current working directory")
-			os.Exit(ExitDirErr)
+	archive := flag.String("archive", "", "analyse the project inside this .zip, .tar or .tar.gz archive; the index file is then a path inside the archive")
+	rev := flag.String("rev", "", "analyse the project at this git revision (branch, tag or commit) without checking it out")
+	unresolved := flag.String("unresolved", "fail", "what to do about imports that cannot be resolved: fail, warn or ignore")
+	importMap := flag.String("importmap", "", "map bare import specifiers to files using this import map (importmap.json)")
+	denoVendor := flag.String("deno-vendor", "", "map URL specifiers to this Deno vendor directory, and npm: specifiers to the node_modules directory next to it")
+	packages := flag.String("packages", "", "comma-separated names of packages to follow into node_modules, ie. local libraries")
+	exclude := flag.String("exclude", "", "comma-separated globs in .gitignore syntax of files to exclude, ie. dist/,**/*.generated.ts")
+	gitignore := flag.Bool("gitignore", false, "exclude the files that are ignored by .gitignore files")
+	discover := flag.String("discover", "", "discover entry files from the package.json file and pages/ and app/ directories of this project directory")
+	timeout := flag.Duration("timeout", 0, "stop the analysis after this long, ie. 30s, and report on the files visited so far")
+	cacheDir := flag.String("cache", "", "keep parsed files in this directory, and only parse the files that changed on the next run")
+	jobs := flag.Int("j", runtime.NumCPU(), "number of files to load and parse concurrently")
+	transitive := flag.Bool("transitive", false, "report the exports that are only used by unreachable code, ie. by unused exports")
+	externals := flag.Bool("externals", false, "list the bare imports that aren't mapped to project files, ie. third-party packages")
+	orphans := flag.Bool("orphans", false, "report the source files of the project that aren't reached from any entry file")
+	interval := flag.Duration("interval", time.Second, "how often to check the visited files for changes in watch mode")
+	printEntries := flag.Bool("print-entries", false, "print the entry files and exit without analysing them")
+	flag.Parse()
+
+	if flag.NArg() == 0 && *discover == "" {
+		exit(ExitMissArgs, "Missing: name of index.js or index.ts file")
+	}
+	entries := flag.Args()
+	for _, entry := range entries {
+		if filepath.Ext(entry) == "" || !script.IsScript(entry) {
+			exit(ExitMissArgs, "Not a script file: %q", entry)
 		}
-		fix = filepath.Join(cwd, fix)
 	}
 
-	// Check if the index file exists.
-	_, err := os.Stat(fix)
+	policy, err := engine.ParseUnresolvedPolicy(*unresolved)
 	if err != nil {
-		fmt.Printf("No such file: %q\n", fix)
-		os.Exit(ExitFileErr)
+		exit(ExitMissArgs, "%s", err)
+	}
+
+	// locate maps paths given on the command line to the paths served by the loader.
+	// root is the project directory that exclude globs and .gitignore files are relative to.
+	var (
+		loader engine.SourceLoader
+		locate func(string) string
+		root   string
+	)
+	switch {
+	case *archive != "" && *rev != "":
+		exit(ExitMissArgs, "Flags -archive and -rev cannot be combined")
+	case watchMode && (*archive != "" || *rev != ""):
+		exit(ExitMissArgs, "Watch mode only works with the files on disk, not with -archive or -rev")
+	case watchMode && *timeout > 0:
+		exit(ExitMissArgs, "Flag -timeout cannot be used in watch mode")
+	case *archive != "":
+		arcl := archiveLoader(*archive)
+		atExit(func() { arcl.Close() })
+		loader, locate, root = arcl, archivePath, "/"
+	case *rev != "":
+		locate = revPath
+		dir := locate(*discover)
+		if len(entries) > 0 {
+			dir = filepath.Dir(locate(entries[0]))
+		}
+		gitl := gitLoader(*rev, dir)
+		atExit(func() { gitl.Close() })
+		loader, root = gitl, gitl.Root()
+	default:
+		loader, locate = loaders.NewFileLoader(), absPath
+		dir := locate(*discover)
+		if len(entries) > 0 {
+			dir = filepath.Dir(locate(entries[0]))
+		}
+		// FileLoader serves files by their real path, so the root must not contain symlinks either.
+		if realDir, err := filepath.EvalSymlinks(dir); err == nil {
+			dir = realDir
+		}
+		root = projectRoot(dir)
+	}
+	for i, entry := range entries {
+		entries[i] = locate(entry)
+	}
+
+	if *exclude != "" || *gitignore {
+		var globs []string
+		if *exclude != "" {
+			globs = strings.Split(*exclude, ",")
+		}
+		loader = loaders.NewExcludeLoader(loader, root, globs, *gitignore)
+	}
+
+	// Most files are resolved several times; once per import statement.
+	cacheLoader := loaders.NewCachingLoader(loader)
+	loader = cacheLoader
+
+	// Check if the entry files exist, and that they aren't excluded, since nothing would be analysed.
+	for _, entry := range entries {
+		res := loader.Resolve(entry)
+		if res == "" {
+			exit(ExitFileErr, "No such file: %q", entry)
+		}
+		if cacheLoader.Excluded(res) {
+			exit(ExitFileErr, "Entry file is excluded: %q", entry)
+		}
+	}
+
+	if *discover != "" {
+		discovered, err := engine.DiscoverEntries(loader, locate(*discover))
+		if err != nil {
+			exit(ExitFileErr, "%s", err)
+		}
+		entries = append(entries, discovered...)
+	}
+	if *printEntries {
+		for _, entry := range entries {
+			fmt.Println(entry)
+		}
+		return
+	}
+
+	opts := []engine.Option{engine.WithUnresolvedPolicy(policy), engine.WithEntries(entries[1:]...), engine.WithWorkers(*jobs)}
+	if *importMap != "" {
+		imap, err := engine.LoadImportMap(loader, locate(*importMap))
+		if err != nil {
+			exit(ExitFileErr, "%s", err)
+		}
+		opts = append(opts, engine.WithResolver(imap))
+	}
+	if *packages != "" {
+		opts = append(opts, engine.WithPackages(strings.Split(*packages, ",")...))
+	}
+	if *transitive {
+		opts = append(opts, engine.WithTransitive())
+	}
+	if *orphans {
+		opts = append(opts, engine.WithOrphans(root))
+	}
+	if *cacheDir != "" {
+		if err := os.MkdirAll(*cacheDir, 0755); err != nil {
+			exit(ExitDirErr, "Unable to create cache directory: %s", err)
+		}
+		opts = append(opts, engine.WithCacheDir(*cacheDir))
+	}
+	if *denoVendor != "" {
+		vendorDir := locate(*denoVendor)
+		nodeModulesDir := filepath.Join(filepath.Dir(vendorDir), "node_modules")
+		opts = append(opts, engine.WithResolver(engine.NewDenoResolver(loader, vendorDir, nodeModulesDir)))
+	}
+
+	// Show the progress when a person is watching.
+	if isTerminal(os.Stderr) {
+		opts = append(opts, engine.WithProgress(progressLine(os.Stderr)))
+	}
+
+	// Stop on Ctrl+C, or when the timeout expires.
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+	if *timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, *timeout)
+		defer cancel()
 	}
 
 	// Parse the project and output the report results.
-	fil := loaders.NewFileLoader()
-	ng := engine.New(fix, fil)
-	rep, err := ng.Start()
-	if err != nil {
-		fmt.Println(err)
-		os.Exit(ExitParserErr)
+	ng := engine.New(entries[0], loader, opts...)
+	rep, err := ng.StartContext(ctx)
+	if err != nil && ctx.Err() == nil {
+		exit(ExitParserErr, "%s", err)
 	}
 	fmt.Println(rep.String())
+	if *externals {
+		fmt.Printf("External imports (%d):\n", len(rep.Externals))
+		for _, spec := range rep.Externals {
+			fmt.Printf("  %s\n", spec)
+		}
+	}
+	if err != nil {
+		exit(ExitCancelled, "Analysis stopped early: %s", err)
+	}
+	if watchMode {
+		watch(ctx, ng, cacheLoader, rep, *interval)
+	}
+}
+
+// watch checks the files visited by ng for changes every interval, until ctx is cancelled. When files have
+// changed, they are analysed again, and the exports that have become unused or used are printed. The directories
+// of the visited files are checked as well, since added files may resolve imports that were unresolved.
+func watch(ctx context.Context, ng *engine.Engine, cacheLoader *loaders.CachingLoader, rep engine.Report, interval time.Duration) {
+	fmt.Println("Watching for changes...")
+	mtimes, dirMtimes := modTimes(ng.Tree().Visited()), modTimes(dirs(ng.Tree().Visited()))
+	var failed []string // The files of a failed update, which are updated again along with the next changes.
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		changed := changedFiles(mtimes)
+		added := len(changedFiles(dirMtimes)) > 0
+		if len(changed) == 0 && !added {
+			continue
+		}
+
+		// Files may have been added or removed, so the resolved paths are stale.
+		cacheLoader.Reset()
+		n := len(changed)
+		for _, fname := range failed {
+			if i := sort.SearchStrings(changed[:n], fname); i == n || changed[i] != fname {
+				changed = append(changed, fname)
+			}
+		}
+		sort.Strings(changed)
+		next, err := ng.Update(ctx, changed...)
+		if ctx.Err() != nil {
+			return
+		}
+		if err != nil {
+			// The update was rolled back. Don't report the same changes again, but keep watching the files,
+			// and update them again along with the changes that fix the error.
+			fmt.Printf("Error: %s\n", err)
+			failed = changed
+			for _, fname := range changed {
+				delete(mtimes, fname)
+			}
+			for fname, mtime := range modTimes(changed) {
+				mtimes[fname] = mtime
+			}
+			dirMtimes = modTimes(dirs(ng.Tree().Visited()))
+			continue
+		}
+		failed = nil
+
+		unused, used := ng.Diff(rep, next)
+		if len(changed) > 0 {
+			fmt.Printf("%d file(s) changed:\n", len(changed))
+		} else {
+			fmt.Println("Files were added or removed:")
+		}
+		for _, exp := range unused {
+			fmt.Printf("  unused: %s:%d %q\n", exp.File, exp.Line, exp.Signature)
+		}
+		for _, exp := range used {
+			fmt.Printf("  used:   %s:%d %q\n", exp.File, exp.Line, exp.Signature)
+		}
+		fmt.Printf("Unused exports in total: %d\n", next.UnusedExports)
+		rep, mtimes, dirMtimes = next, modTimes(ng.Tree().Visited()), modTimes(dirs(ng.Tree().Visited()))
+	}
+}
+
+// modTimes returns the modification times of the given files. Files that don't exist are left out.
+func modTimes(fnames []string) map[string]time.Time {
+	mtimes := make(map[string]time.Time, len(fnames))
+	for _, fname := range fnames {
+		if fi, err := os.Stat(fname); err == nil {
+			mtimes[fname] = fi.ModTime()
+		}
+	}
+	return mtimes
+}
+
+// dirs returns the distinct directories of the given files.
+func dirs(fnames []string) []string {
+	seen := make(map[string]bool)
+	dirs := make([]string, 0)
+	for _, fname := range fnames {
+		if dir := filepath.Dir(fname); !seen[dir] {
+			seen[dir] = true
+			dirs = append(dirs, dir)
+		}
+	}
+	return dirs
+}
+
+// changedFiles returns the files in mtimes that have been modified or removed since, sorted by name.
+func changedFiles(mtimes map[string]time.Time) []string {
+	changed := make([]string, 0)
+	for fname, mtime := range mtimes {
+		if fi, err := os.Stat(fname); err != nil || !fi.ModTime().Equal(mtime) {
+			changed = append(changed, fname)
+		}
+	}
+	sort.Strings(changed)
+	return changed
+}
+
+// archiveLoader opens the given archive. Paths inside the archive are served as if the archive was extracted to "/".
+func archiveLoader(archive string) *loaders.ArchiveLoader {
+	arcl, err := loaders.OpenArchive(archive)
+	if err != nil {
+		exit(ExitFileErr, "%s", err)
+	}
+	return arcl
+}
+
+// archivePath maps fix to the path that it has inside an archive.
+func archivePath(fix string) string {
+	return path.Join("/", filepath.ToSlash(fix))
+}
+
+// gitLoader returns a loader for the git revision rev of the repository that contains dir.
+func gitLoader(rev, dir string) *loaders.GitLoader {
+	gitl, err := loaders.NewGitLoader(dir, rev)
+	if err != nil {
+		exit(ExitFileErr, "%s", err)
+	}
+	return gitl
+}
+
+// revPath resolves fix to an absolute path with any symlinks resolved, so it matches the paths served by
+// a GitLoader. The file may not exist in the working tree, but its directory should.
+func revPath(fix string) string {
+	fix = absPath(fix)
+	dir, err := filepath.EvalSymlinks(filepath.Dir(fix))
+	if err != nil {
+		exit(ExitDirErr, "No such directory: %q", filepath.Dir(fix))
+	}
+	return filepath.Join(dir, filepath.Base(fix))
+}
+
+// projectRoot returns the project directory that contains dir, ie. the nearest directory with a package.json file
+// or a .git directory. Returns dir if there is none.
+func projectRoot(dir string) string {
+	for d := dir; ; d = filepath.Dir(d) {
+		for _, marker := range []string{"package.json", ".git"} {
+			if _, err := os.Stat(filepath.Join(d, marker)); err == nil {
+				return d
+			}
+		}
+		if filepath.Dir(d) == d {
+			return dir
+		}
+	}
+}
+
+// absPath resolves fix to an absolute path.
+func absPath(fix string) string {
+	if filepath.IsAbs(fix) {
+		return fix
+	}
+	cwd, err := os.Getwd()
+	if err != nil {
+		exit(ExitDirErr, "Unable to determine current working directory")
+	}
+	return filepath.Join(cwd, fix)
+}
+
+// isTerminal returns true if f is a terminal, as opposed to a file or pipe.
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
+
+// progressLine returns a callback that renders the progress of an analysis on a single line of f. The line is
+// redrawn at most ten times per second, and cleared when the analysis is done.
+func progressLine(f *os.File) func(engine.Event) {
+	var last time.Time
+	return func(ev engine.Event) {
+		if ev.Phase == engine.PhaseDone {
+			fmt.Fprint(f, "\r\033[K")
+			return
+		}
+		if ev.Kind != engine.EventPhase && time.Since(last) < 100*time.Millisecond {
+			return
+		}
+		last = time.Now()
+		fmt.Fprintf(f, "\r\033[K%s: %d of %d files parsed, %d queued", ev.Phase, ev.Parsed, ev.Discovered, ev.Queued)
+	}
+}
+
+// cleanups are the functions registered with atExit.
+var cleanups []func()
+
+// atExit registers fn to be called when main returns or exit is called, ie. to close an archive.
+// Deferred calls are not enough, since os.Exit doesn't run them.
+func atExit(fn func()) {
+	cleanups = append(cleanups, fn)
+}
+
+// cleanup calls the functions registered with atExit in reverse order.
+func cleanup() {
+	for i := len(cleanups) - 1; i >= 0; i-- {
+		cleanups[i]()
+	}
+	cleanups = nil
+}
+
+// exit prints the given message and exits with the given exit code.
+func exit(code int, format string, args ...interface{}) {
+	cleanup()
+	fmt.Printf(format+"\n", args...)
+	os.Exit(code)
 }
@@ -0,0 +1,76 @@
+package engine
+
+import (
+	"strings"
+)
+
+// resolveAmbientImports maps the bare imports of all visited files to the files that declare them as ambient
+// modules, ie. declare module 'name' { ... }. This happens after the traversal, since an import may be visited
+// before the declaration. Declaring files are already part of the FileTree, so no further traversal is needed.
+func (ng *Engine) resolveAmbientImports() {
+	if len(ng.ambient) == 0 {
+		return
+	}
+
+	for _, file := range ng.tree {
+		for key, imp := range file.BareImports {
+			decl := ng.ambientModule(imp.RelPath)
+			if decl == "" {
+				continue
+			}
+			delete(file.BareImports, key)
+			imp.RelPath = decl
+			file.Imports[key] = imp
+		}
+	}
+}
+
+// ambientModule returns the path of the file that declares the ambient module spec. Declarations may contain
+// a single "*" wildcard, ie. declare module '*.svg'; exact declarations take precedence.
+// Returns an empty string if there is none.
+func (ng *Engine) ambientModule(spec string) string {
+	if decl, ok := ng.ambient[spec]; ok {
+		return decl
+	}
+
+	var best, decl string
+	for name, fname := range ng.ambient {
+		i := strings.IndexByte(name, '*')
+		if i < 0 || len(name)-1 > len(spec) {
+			continue
+		}
+		if strings.HasPrefix(spec, name[:i]) && strings.HasSuffix(spec, name[i+1:]) && len(name) > len(best) {
+			best, decl = name, fname
+		}
+	}
+	return decl
+}
+
+// declaresAmbient returns true if the file fname is the registered declaration of any ambient module.
+func (ng *Engine) declaresAmbient(fname string) bool {
+	for _, decl := range ng.ambient {
+		if decl == fname {
+			return true
+		}
+	}
+	return false
+}
+
+// ambientDeclarer returns the path of a visited file that declares the ambient module name, ie. to take over
+// from a file that was forgotten. If several files declare it, the first by path is returned.
+// Returns an empty string if there is none.
+func (ng *Engine) ambientDeclarer(name string) string {
+	var decl string
+	for fname, file := range ng.tree {
+		if decl != "" && fname > decl {
+			continue
+		}
+		for _, declared := range file.AmbientModules {
+			if declared == name {
+				decl = fname
+				break
+			}
+		}
+	}
+	return decl
+}
@@ -0,0 +1,98 @@
+package engine
+
+import (
+	"path/filepath"
+	"strings"
+)
+
+// A CaseMismatch is an import statement whose path differs in casing from the file that it refers to.
+// Such imports work on case-insensitive file systems, but fail on case-sensitive ones.
+type CaseMismatch struct {
+	File       string // The importing file.
+	Line       int
+	Path       string // The path as written in the import statement.
+	Suggestion string // The path with the correct casing.
+}
+
+// correctCase returns fname with each path component below dir replaced by the directory entry that it matches
+// case-insensitively, if any. The last component may lack a file extension. Components without a matching
+// directory entry are left untouched. Returns fname unchanged if the loader is unable to list directories.
+func (ng *Engine) correctCase(fname, dir string) string {
+	dr, ok := ng.loader.(DirReader)
+	if !ok {
+		return fname
+	}
+
+	// Components that are part of dir are known to be correct.
+	parent := commonDir(fname, dir)
+	rest := strings.TrimPrefix(strings.TrimPrefix(fname, parent), string(filepath.Separator))
+	if rest == "" {
+		return fname
+	}
+	comps := strings.Split(rest, string(filepath.Separator))
+
+	for i, comp := range comps {
+		entries, err := dr.ReadDir(parent)
+		if err != nil {
+			return fname
+		}
+		last := i == len(comps)-1
+		var match string
+		for _, entry := range entries {
+			name := entry.Name()
+			if name == comp || last && strings.HasPrefix(name, comp+".") {
+				// The casing is correct.
+				match = comp
+				break
+			}
+			if match != "" {
+				continue
+			}
+			if strings.EqualFold(name, comp) {
+				match = name
+			} else if last && len(name) > len(comp) && name[len(comp)] == '.' && strings.EqualFold(name[:len(comp)], comp) {
+				match = name[:len(comp)]
+			}
+		}
+		if match == "" {
+			return fname
+		}
+		comps[i] = match
+		parent = filepath.Join(parent, match)
+	}
+
+	return parent
+}
+
+// commonDir returns the longest directory path that is shared between fname and dir.
+func commonDir(fname, dir string) string {
+	sep := string(filepath.Separator)
+	for dir != "" && dir != sep && dir != "." {
+		if strings.HasPrefix(fname, dir+sep) {
+			return dir
+		}
+		dir = filepath.Dir(dir)
+	}
+	return dir
+}
+
+// suggestPath returns the import path relPath with its components replaced by the correctly cased components
+// from the end of fname.
+func suggestPath(relPath, fname string) string {
+	cleaned := filepath.ToSlash(filepath.Clean(relPath))
+	segs := strings.Split(cleaned, "/")
+	comps := strings.Split(filepath.ToSlash(fname), "/")
+
+	for i, j := len(segs)-1, len(comps)-1; i >= 0 && j >= 0; i, j = i-1, j-1 {
+		if segs[i] == ".." || segs[i] == "." || segs[i] == "" {
+			break
+		}
+		segs[i] = comps[j]
+	}
+
+	suggestion := strings.Join(segs, "/")
+	if strings.HasPrefix(relPath, "./") && !strings.HasPrefix(suggestion, ".") {
+		suggestion = "./" + suggestion
+	}
+	return suggestion
+}
@@ -0,0 +1,22 @@
+package engine
+
+import (
+	"testing"
+)
+
+func TestSuggestPath(t *testing.T) {
+	cases := []struct {
+		relPath, fname, expected string
+	}{
+		{"./Button", "/projectA/button", "./button"},
+		{"./Components/Button", "/projectA/components/Button", "./components/Button"},
+		{"../Lib/./Utils", "/projectA/lib/utils", "../lib/utils"},
+		{"../../shared/Icon.js", "/shared/icon.js", "../../shared/icon.js"},
+	}
+
+	for _, c := range cases {
+		if actual := suggestPath(c.relPath, c.fname); actual != c.expected {
+			t.Fatalf("suggestPath(%q, %q): expected %q, got %q", c.relPath, c.fname, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package engine
+
+import (
+	"sort"
+	"strings"
+)
+
+// A DeclarationMismatch is an export that is found in only one of a declaration file (.d.ts) and its
+// implementation (.js).
+type DeclarationMismatch struct {
+	File      string // The file that contains the export.
+	Line      int
+	Name      string
+	Companion string // The file that lacks the export.
+}
+
+// companionOf returns the path of the declaration file of the implementation file fname, or vice versa.
+// Returns an empty string if there is none.
+func (ng *Engine) companionOf(fname string) string {
+	var companion string
+	switch {
+	case strings.HasSuffix(fname, ".d.ts"):
+		companion = strings.TrimSuffix(fname, ".d.ts") + ".js"
+	case strings.HasSuffix(fname, ".js"):
+		companion = strings.TrimSuffix(fname, ".js") + ".d.ts"
+	default:
+		return ""
+	}
+	if companion = ng.loader.Resolve(companion); ng.excluded(companion) {
+		return ""
+	}
+	return companion
+}
+
+// reconcileDeclarations pairs the exports of each declaration file with the exports of its implementation.
+// Paired exports share their RefCounts, since importing either one means using both. Unpaired exports are
+// returned as DeclarationMismatches, sorted by file and line.
+func (ng *Engine) reconcileDeclarations() []DeclarationMismatch {
+	mismatches := make([]DeclarationMismatch, 0)
+
+	for decl, impl := range ng.companions {
+		if !strings.HasSuffix(decl, ".d.ts") {
+			continue
+		}
+		declFile, implFile := ng.tree[decl], ng.tree[impl]
+		if declFile == nil || implFile == nil {
+			continue
+		}
+
+		for _, declExp := range declFile.Exports {
+			if declExp.Name == "" {
+				continue
+			}
+			var found bool
+			for _, implExp := range implFile.Exports {
+				if implExp.Name == declExp.Name {
+					found = true
+					sum := declExp.RefCount + implExp.RefCount
+					declExp.RefCount, implExp.RefCount = sum, sum
+				}
+			}
+			if !found {
+				mismatches = append(mismatches, DeclarationMismatch{File: ng.relPath(decl), Line: declExp.Line, Name: declExp.Name, Companion: ng.relPath(impl)})
+			}
+		}
+
+		for _, implExp := range implFile.Exports {
+			if implExp.Name == "" {
+				continue
+			}
+			var found bool
+			for _, declExp := range declFile.Exports {
+				if declExp.Name == implExp.Name {
+					found = true
+					break
+				}
+			}
+			if !found {
+				mismatches = append(mismatches, DeclarationMismatch{File: ng.relPath(impl), Line: implExp.Line, Name: implExp.Name, Companion: ng.relPath(decl)})
+			}
+		}
+	}
+
+	sort.Slice(mismatches, func(i, j int) bool {
+		a, b := mismatches[i], mismatches[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Name < b.Name
+	})
+	return mismatches
+}
@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// A DenoResolver maps the URL and "npm:" specifiers of Deno projects to vendored files, without any network access.
+// URL specifiers are mapped to the vendor directory, using the same layout as Deno's "vendor" option, ie.
+// "https://deno.land/x/oak@v12.6.1/mod.ts" is mapped to "vendor/deno.land/x/oak@v12.6.1/mod.ts".
+// "npm:" specifiers are mapped to the node_modules directory, ie. "npm:chalk@5/ansi" is mapped to
+// "node_modules/chalk/ansi". Specifiers that aren't vendored are left alone and are thus considered external.
+type DenoResolver struct {
+	loader                    SourceLoader
+	vendorDir, nodeModulesDir string
+}
+
+// NewDenoResolver returns a new DenoResolver that uses loader to look for vendored files.
+func NewDenoResolver(loader SourceLoader, vendorDir, nodeModulesDir string) *DenoResolver {
+	return &DenoResolver{loader: loader, vendorDir: vendorDir, nodeModulesDir: nodeModulesDir}
+}
+
+// ResolveSpecifier returns the path of the vendored file that spec refers to.
+// Returns an empty string if spec is neither a URL nor an "npm:" specifier, or if it isn't vendored.
+func (deno *DenoResolver) ResolveSpecifier(spec, importer string) string {
+	var res string
+	switch {
+	case strings.HasPrefix(spec, "npm:"):
+		name, sub := splitPackage(strings.TrimPrefix(strings.TrimPrefix(spec, "npm:"), "/"))
+		if name == "" {
+			return ""
+		}
+		res = filepath.Join(deno.nodeModulesDir, name, filepath.FromSlash(sub))
+	case strings.HasPrefix(spec, "https://") || strings.HasPrefix(spec, "http://"):
+		u, err := url.Parse(spec)
+		if err != nil || u.Host == "" {
+			return ""
+		}
+		res = filepath.Join(deno.vendorDir, strings.Replace(u.Host, ":", "_", 1), filepath.FromSlash(u.Path))
+	default:
+		return ""
+	}
+
+	if deno.loader.Resolve(res) == "" {
+		return ""
+	}
+	return res
+}
+
+// splitPackage splits a package specifier, ie. "@scope/name@1.2.3/sub/path", into the package name
+// without its version and the sub path.
+func splitPackage(spec string) (string, string) {
+	segs := strings.SplitN(spec, "/", 3)
+	n := 1
+	if strings.HasPrefix(spec, "@") {
+		n = 2
+	}
+	if len(segs) < n || segs[n-1] == "" {
+		return "", ""
+	}
+
+	// Strip the version from the last segment of the name. Scoped names start with "@", so we skip that.
+	if i := strings.LastIndexByte(segs[n-1], '@'); i > 0 {
+		segs[n-1] = segs[n-1][:i]
+	}
+	return strings.Join(segs[:n], "/"), strings.Join(segs[n:], "/")
+}
@@ -0,0 +1,51 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mkock/esclean/engine/loaders"
+)
+
+func TestSplitPackage(t *testing.T) {
+	cases := map[string][2]string{
+		"chalk":                      {"chalk", ""},
+		"chalk@5.3.0":                {"chalk", ""},
+		"chalk@5/ansi":               {"chalk", "ansi"},
+		"@std/path@1.0.0/posix/join": {"@std/path", "posix/join"},
+		"@std":                       {"", ""},
+	}
+
+	for in, expected := range cases {
+		name, sub := splitPackage(in)
+		if name != expected[0] || sub != expected[1] {
+			t.Fatalf("splitPackage(%q): expected %q, got %q", in, expected, [2]string{name, sub})
+		}
+	}
+}
+
+func TestEngineWithDenoResolver(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/main.ts": `
+import { serve } from "https://deno.land/std@0.200.0/http/server.ts"
+import chalk from "npm:chalk@5"
+import { Hono } from "https://deno.land/x/hono@v3.4.1/mod.ts"
+`,
+		"/projectA/vendor/deno.land/std@0.200.0/http/server.ts": "export function serve() {\n}\nexport function unused() {\n}",
+		"/projectA/node_modules/chalk/index.js":                 "export default chalk",
+	}
+	memload := loaders.NewMemLoader(fileset)
+	deno := NewDenoResolver(memload, "/projectA/vendor", "/projectA/node_modules")
+	ng := New("/projectA/main.ts", memload, WithResolver(deno))
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesChecked != 3 {
+		t.Fatalf("Expected 3 checked files, got %d", report.FilesChecked)
+	}
+	expected := []string{"https://deno.land/x/hono@v3.4.1/mod.ts"}
+	if !reflect.DeepEqual(report.Externals, expected) {
+		t.Fatalf("Expected externals %q, got %q", expected, report.Externals)
+	}
+}
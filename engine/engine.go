@@ -1,19 +1,41 @@
 package engine
 
 import (
+	"context"
 	"fmt"
-	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
-
-	"github.com/mkock/esclean/script"
 )
 
 // A Report contains the final source code analysis, including the output lines.
 type Report struct {
 	FilesChecked, UnusedExports int
 	Errors, Results             []string
+	Unused                      []UnusedExport // The unused exports that Results lists, sorted by file and line.
+	Unresolved                  []UnresolvedImport
+	CaseMismatches              []CaseMismatch
+	Externals                   []string // Bare import specifiers that aren't mapped to project files.
+	Packages                    []PackageReport
+	DeclarationMismatches       []DeclarationMismatch
+	DeadExports                 []DeadExport // Only set if WithTransitive is used.
+	Orphans                     []Orphan     // Only set if WithOrphans is used.
+	OrphanLines                 int          // The total line count of the orphan files.
+}
+
+// An UnusedExport is an export statement that no visited file imports.
+type UnusedExport struct {
+	File      string
+	Line      int
+	Signature string
+}
+
+// An UnresolvedImport is an import statement whose path could not be resolved to a file.
+type UnresolvedImport struct {
+	File string // The importing file.
+	Line int
+	Path string // The path as written in the import statement.
 }
 
 // String returns the report results with a line per finding.
@@ -28,6 +50,48 @@ func (rep Report) String() string {
 		fmt.Fprintf(&b, "  %s", line)
 	}
 
+	if len(rep.DeadExports) > 0 {
+		fmt.Fprintln(&b, "Transitively unused exports:")
+		for _, dead := range rep.DeadExports {
+			fmt.Fprintf(&b, "  %s:%d %q\n", dead.File, dead.Line, dead.Signature)
+			for _, line = range dead.Chain {
+				fmt.Fprintf(&b, "    used by %s\n", line)
+			}
+		}
+	}
+
+	if len(rep.Orphans) > 0 {
+		fmt.Fprintf(&b, "Orphan files (%d lines in total):\n", rep.OrphanLines)
+		for _, orphan := range rep.Orphans {
+			fmt.Fprintf(&b, "  %s (%d lines)\n", orphan.File, orphan.Lines)
+		}
+	}
+
+	if len(rep.Unresolved) > 0 {
+		fmt.Fprintln(&b, "Unresolved imports:")
+		for _, unres := range rep.Unresolved {
+			fmt.Fprintf(&b, "  %s:%d %q\n", unres.File, unres.Line, unres.Path)
+		}
+	}
+
+	if len(rep.CaseMismatches) > 0 {
+		fmt.Fprintln(&b, "Case mismatches:")
+		for _, mismatch := range rep.CaseMismatches {
+			fmt.Fprintf(&b, "  %s:%d %q should be %q\n", mismatch.File, mismatch.Line, mismatch.Path, mismatch.Suggestion)
+		}
+	}
+
+	if len(rep.DeclarationMismatches) > 0 {
+		fmt.Fprintln(&b, "Declaration mismatches:")
+		for _, mismatch := range rep.DeclarationMismatches {
+			verb := "implemented"
+			if strings.HasSuffix(mismatch.Companion, ".d.ts") {
+				verb = "declared"
+			}
+			fmt.Fprintf(&b, "  %s:%d %q is not %s in %s\n", mismatch.File, mismatch.Line, mismatch.Name, verb, mismatch.Companion)
+		}
+	}
+
 	if len(rep.Errors) > 0 {
 		fmt.Fprintln(&b, "Errors:")
 		for _, line = range rep.Errors {
@@ -35,71 +99,140 @@ func (rep Report) String() string {
 		}
 	}
 
+	for _, pkg := range rep.Packages {
+		fmt.Fprintf(&b, "Package %q (%d used, %d unused exports):\n", pkg.Name, len(pkg.UsedExports), len(pkg.UnusedExports))
+		for _, line = range pkg.UnusedExports {
+			fmt.Fprintf(&b, "  %s", line)
+		}
+	}
+
 	fmt.Fprintf(&b, "\nUnused exports in total: %d\n", rep.UnusedExports)
 
 	return b.String()
 }
 
-// An Engine will - given a base path to an EcmaScript project directory - traverse that directory,
+// An Engine will - given a set of entry files of an EcmaScript project - traverse that project,
 // parse the import statements and follow them recursively while parsing each file
 // exactly once.
 type Engine struct {
-	basePath, index string
-	loader          SourceLoader
-	tree            FileTree
+	basePath         string
+	entries          []string
+	loader           SourceLoader
+	tree             FileTree
+	unresolvedPolicy UnresolvedPolicy
+	unresolved       []UnresolvedImport
+	mismatches       []CaseMismatch
+	resolvers        []SpecifierResolver
+	packages         *PackageResolver
+	companions       map[string]string // Declaration file <-> implementation.
+	ambient          map[string]string // Ambient module name -> declaring file.
+	orphanDir        string
+	transitive       bool
+	workers          int
+	progress         progress
+	cache            *parseCache
 }
 
 // New creates and returns a new Engine.
-// index should be an absolute path to the main (index) file of the EcmaScript project.
-func New(index string, loader SourceLoader) *Engine {
-	pname, fname := path.Split(index)
+// index should be an absolute path to the main (index) file of the EcmaScript project. Use WithEntries
+// to add more entry files.
+func New(index string, loader SourceLoader, opts ...Option) *Engine {
 	tree := make(FileTree, 100)
 	ng := Engine{
-		basePath: pname, index: fname, loader: loader, tree: tree,
+		entries: []string{index}, loader: loader, tree: tree, companions: make(map[string]string),
+		ambient: make(map[string]string), workers: runtime.NumCPU(),
+	}
+	for _, opt := range opts {
+		opt(&ng)
 	}
 	return &ng
 }
 
 // Start starts the engine.
 func (ng *Engine) Start() (Report, error) {
-	index := filepath.Join(ng.basePath, ng.index)
-	queue := make([]*script.File, 0, 10)
-	var i int
+	return ng.StartContext(context.Background())
+}
 
-	// Visit the index file.
-	file, err := ng.visit(index)
-	if err != nil {
+// StartContext starts the engine, and stops it when ctx is cancelled. The context is checked between files and
+// passed on to loaders that implement ContextLoader, which can only cancel loading, not resolving. If ctx is
+// cancelled, the Report covers the files that were visited so far, and ctx.Err() is returned along with it. Orphans
+// and transitively unused exports are left out, since they require a complete analysis.
+func (ng *Engine) StartContext(ctx context.Context) (Report, error) {
+	// Resolve the entry files. The loader may canonicalise their paths, so the base path is derived from the result.
+	entries := make([]string, 0, len(ng.entries))
+	seen := make(map[string]bool, len(ng.entries))
+	for _, entry := range ng.entries {
+		res := ng.loader.Resolve(entry)
+		if res == "" {
+			return Report{}, fmt.Errorf("unable to resolve file %q", entry)
+		}
+		// Ignore entries that are listed more than once.
+		if !seen[res] {
+			seen[res] = true
+			entries = append(entries, res)
+		}
+	}
+	ng.entries = entries
+	ng.basePath = baseDir(entries)
+
+	// Follow imports. PhaseDone is sent however the analysis ends, so callbacks can tidy up.
+	ng.progress.enter(PhaseTraverse)
+	defer ng.progress.enter(PhaseDone)
+	err := ng.traverse(ctx, entries)
+	if err != nil && ctx.Err() == nil {
 		return Report{}, err
 	}
-	queue = append(queue, file)
+	return ng.analyse(ctx, err != nil)
+}
+
+// analyse counts the references of the visited files and creates the Report. If cancelled is true, the traversal
+// was stopped early, so only a partial Report is created and ctx.Err() is returned along with it.
+func (ng *Engine) analyse(ctx context.Context, cancelled bool) (Report, error) {
+	// Update all RefCounts. If cancelled, some imports refer to files that weren't visited.
+	ng.progress.enter(PhaseRefCounts)
+	ng.resolveAmbientImports()
+	ng.tree.updateRefCounts(!cancelled)
+	mismatches := ng.reconcileDeclarations()
 
-	// Follow imports.
-	for {
-		fis, err := ng.visitImports(queue[i])
-		if err != nil {
+	// Create final report.
+	report := ng.createReport()
+	report.DeclarationMismatches = mismatches
+	if cancelled {
+		return report, ctx.Err()
+	}
+	if ng.transitive {
+		ng.progress.enter(PhaseLiveness)
+		report.DeadExports = ng.findDeadExports()
+	}
+	if ng.orphanDir != "" {
+		ng.progress.enter(PhaseOrphans)
+		var err error
+		if report.Orphans, report.OrphanLines, err = ng.findOrphans(); err != nil {
 			return Report{}, err
 		}
-		if len(fis) > 0 {
-			queue = append(queue, fis...)
-			// Reset already processed queue items.
-			queue[i] = nil
-		}
-		i++
-		// Allow the GC to run every 10,000 items.
-		if i%10000 == 0 {
-			runtime.GC()
-		}
-		if i == len(queue) {
-			break
-		}
 	}
-	queue = nil
+	return report, nil
+}
 
-	// Update all RefCounts.
-	ng.tree.UpdateRefCounts()
+// Entries returns the paths of the entry files. Once the engine has started, the paths are as resolved
+// by the loader.
+func (ng *Engine) Entries() []string {
+	return ng.entries
+}
 
-	// Create final report.
-	return ng.createReport(), nil
+// baseDir returns the deepest directory that contains all of the given files, with a trailing separator.
+func baseDir(files []string) string {
+	if len(files) == 0 {
+		return ""
+	}
+	dir := filepath.Dir(files[0])
+	for _, fname := range files[1:] {
+		dir = commonDir(fname, dir)
+	}
+	if !strings.HasSuffix(dir, string(filepath.Separator)) {
+		dir += string(filepath.Separator)
+	}
+	return dir
 }
 
 func (ng *Engine) createReport() Report {
@@ -112,82 +245,80 @@ func (ng *Engine) createReport() Report {
 
 	report.FilesChecked = len(ng.tree)
 
+	// Exports of followed packages are reported separately.
 	exps := ng.tree.FindExports(0)
+	sort.Slice(exps, func(i, j int) bool {
+		if exps[i].FileRef.RelPath != exps[j].FileRef.RelPath {
+			return exps[i].FileRef.RelPath < exps[j].FileRef.RelPath
+		}
+		if exps[i].Line != exps[j].Line {
+			return exps[i].Line < exps[j].Line
+		}
+		return exps[i].Signature < exps[j].Signature
+	})
 	for _, exp := range exps {
-		fname := fmt.Sprintf("./%s", strings.TrimPrefix(exp.FileRef.RelPath, ng.basePath))
-		txt = fmt.Sprintf("%s:%d %q\n", fname, exp.Line, exp.Signature)
+		if ng.isPackageExport(exp) {
+			continue
+		}
+		unused := UnusedExport{File: ng.relPath(exp.FileRef.RelPath), Line: exp.Line, Signature: exp.Signature}
+		txt = fmt.Sprintf("%s:%d %q\n", unused.File, unused.Line, unused.Signature)
 		res = append(res, txt)
+		report.Unused = append(report.Unused, unused)
 	}
 
 	report.Results = res
-	report.UnusedExports = len(exps)
-
-	return report
-}
-
-// visitImports takes a *script.File, visits it, follows all import statements exactly one level down,
-// parses each one and returns a slice of *script.File's; one per import statement.
-func (ng *Engine) visitImports(file *script.File) ([]*script.File, error) {
-	var (
-		fi  *script.File
-		err error
-	)
-
-	fis := make([]*script.File, 0, len(file.Imports))
-
-	for _, imp := range file.Imports {
-		if fi, err = ng.visit(imp.RelPath); err != nil {
-			return fis, err
+	report.UnusedExports = len(res)
+	if ng.packages != nil {
+		report.Packages = ng.createPackageReports()
+	}
+	// The findings are copied, since Update modifies them in place.
+	report.Unresolved = append([]UnresolvedImport(nil), ng.unresolved...)
+	sort.Slice(report.Unresolved, func(i, j int) bool {
+		a, b := report.Unresolved[i], report.Unresolved[j]
+		if a.File != b.File {
+			return a.File < b.File
 		}
-		// Ignore already visited files.
-		if fi != nil {
-			fis = append(fis, fi)
+		if a.Line != b.Line {
+			return a.Line < b.Line
 		}
-	}
+		return a.Path < b.Path
+	})
+	report.Externals = ng.tree.Externals()
+	report.CaseMismatches = append([]CaseMismatch(nil), ng.mismatches...)
+	sort.Slice(report.CaseMismatches, func(i, j int) bool {
+		a, b := report.CaseMismatches[i], report.CaseMismatches[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Path < b.Path
+	})
 
-	return fis, nil
+	return report
 }
 
-// visit loads the file contents, parses them into a script.File, and finally updates the FileTree.
-func (ng *Engine) visit(file string) (*script.File, error) {
-	// Resolve the file.
-	resFile := ng.loader.Resolve(file)
-	if resFile == "" {
-		return &script.File{}, fmt.Errorf("unable to resolve file %q", file)
-	}
-	file = resFile
-
-	// Did we visit this file before?
-	if _, ok := ng.tree[file]; ok {
-		return nil, nil
-	}
-
-	fi := script.NewFile(file)
-
-	// Load the file.
-	rc, err := ng.loader.Load(file)
-	if err != nil {
-		return fi, err
-	}
-	defer rc.Close()
+// relPath returns the path of fname relative to the project's base path, for use in reports.
+func (ng *Engine) relPath(fname string) string {
+	return fmt.Sprintf("./%s", strings.TrimPrefix(fname, ng.basePath))
+}
 
-	// Parse the file.
-	if fi, err = script.Parse(rc, file); err != nil {
-		return fi, err
-	}
-	// Resolve each import statement.
-	for _, imp := range fi.Imports {
-		resImpFile := ng.loader.Resolve(filepath.Join(filepath.Dir(file), imp.RelPath))
-		if resImpFile == "" {
-			return &script.File{}, fmt.Errorf("unable to resolve file %q", imp.RelPath)
+// resolveSpecifier returns the path of the file that the bare import specifier spec refers to, according to
+// the first resolver that handles it. Returns an empty string if no resolver does.
+func (ng *Engine) resolveSpecifier(spec, importer string) string {
+	for _, resolver := range ng.resolvers {
+		if res := resolver.ResolveSpecifier(spec, importer); res != "" {
+			return res
 		}
-		imp.RelPath = resImpFile
 	}
+	return ""
+}
 
-	// Remember the visit.
-	ng.tree[file] = fi
-
-	return fi, nil
+// excluded returns true if the loader excludes fname, in which case it is neither loaded nor reported.
+func (ng *Engine) excluded(fname string) bool {
+	ex, ok := ng.loader.(Excluder)
+	return ok && ex.Excluded(fname)
 }
 
 // Tree returns a reference to the FileTree.
@@ -1,6 +1,14 @@
 package engine
 
 import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
 
 	"github.com/mkock/esclean/engine/loaders"
@@ -166,3 +174,505 @@ export function func3(var1, var2, var3) {
 		t.Fatalf("Expected 1 unused export, got %d", report.UnusedExports)
 	}
 }
+
+func TestEngineWithUnresolvedImports(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": `
+import { hackPentagon } from './firstFile'
+import { missing, alsoMissing } from './missingFile'
+`,
+		"/projectA/firstFile.js": `
+import { typo } from './secondFiel'
+
+export function hackPentagon() {
+    return 'Hacked!'
+}`,
+	}
+
+	t.Run("fails by default", func(t *testing.T) {
+		ng := New("/projectA/index.js", loaders.NewMemLoader(fileset))
+		if _, err := ng.Start(); err == nil {
+			t.Fatal("Expected an error for unresolved imports")
+		}
+	})
+
+	t.Run("warns", func(t *testing.T) {
+		ng := New("/projectA/index.js", loaders.NewMemLoader(fileset), WithUnresolvedPolicy(UnresolvedWarn))
+		report, err := ng.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.FilesChecked != 2 {
+			t.Fatalf("Expected 2 checked files, got %d", report.FilesChecked)
+		}
+		expected := []UnresolvedImport{
+			{File: "./firstFile.js", Line: 2, Path: "./secondFiel"},
+			{File: "./index.js", Line: 3, Path: "./missingFile"},
+		}
+		if !reflect.DeepEqual(report.Unresolved, expected) {
+			t.Fatalf("Expected %v, got %v", expected, report.Unresolved)
+		}
+	})
+
+	t.Run("ignores", func(t *testing.T) {
+		ng := New("/projectA/index.js", loaders.NewMemLoader(fileset), WithUnresolvedPolicy(UnresolvedIgnore))
+		report, err := ng.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(report.Unresolved) != 0 {
+			t.Fatalf("Expected no unresolved imports, got %v", report.Unresolved)
+		}
+	})
+}
+
+func TestEngineWithSymlinkedImports(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esclean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileset := map[string]string{
+		"index.js":     "import { hello } from './lib'\nimport { goodbye } from './linked'",
+		"lib/index.js": "export function hello() {\n}\nexport function goodbye() {\n}\nexport function unused() {\n}",
+	}
+	for name, content := range fileset {
+		if err = os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = os.Mkdir(filepath.Join(dir, "linked"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(filepath.Join(dir, "lib/index.js"), filepath.Join(dir, "linked/index.js")); err != nil {
+		t.Skip("unable to create symlinks:", err)
+	}
+
+	ng := New(filepath.Join(dir, "index.js"), loaders.NewFileLoader())
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesChecked != 2 {
+		t.Fatalf("Expected 2 checked files, got %d", report.FilesChecked)
+	}
+	if report.UnusedExports != 1 {
+		t.Fatalf("Expected 1 unused export, got %d", report.UnusedExports)
+	}
+	if report.Results[0] != "./lib/index.js:5 \"export function unused()\"\n" {
+		t.Fatalf("Unexpected result: %q", report.Results[0])
+	}
+}
+
+func TestEngineWithAssetImports(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": `
+import data from './data.json'
+import styles from './App.module.css'
+import logo from './logo.svg'
+import './global.css'
+
+export const App = () => <img src={logo} className={styles.logo} title={data.title} />
+`,
+		"/projectA/data.json": `{"title": "Hello"}`,
+		"/projectA/App.module.css": `
+.logo {
+	width: 100px;
+}
+
+.unusedClass {
+	color: red;
+}
+`,
+		"/projectA/logo.svg":   "<svg></svg>",
+		"/projectA/global.css": "body {\n\tmargin: 0;\n}\n",
+	}
+	memload := loaders.NewMemLoader(fileset)
+	ng := New("/projectA/index.js", memload)
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesChecked != 5 {
+		t.Fatalf("Expected 5 checked files, got %d", report.FilesChecked)
+	}
+	expected := []string{
+		"./App.module.css:6 \".unusedClass\"\n",
+		"./index.js:7 \"export const App = () => <img src={logo} className={styles.logo} title={data.title} />\"\n",
+	}
+	sort.Strings(report.Results)
+	if !reflect.DeepEqual(report.Results, expected) {
+		t.Fatalf("Expected %q, got %q", expected, report.Results)
+	}
+}
+
+func TestEngineWithCaseMismatches(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": `
+import { Button } from './Components/Button'
+import { Icon } from './components/icon'
+`,
+		"/projectA/components/button.js": "export function Button() {\n}",
+		"/projectA/components/Icon.js":   "export function Icon() {\n}",
+	}
+	memload := loaders.NewMemLoader(fileset)
+	ng := New("/projectA/index.js", memload)
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesChecked != 3 {
+		t.Fatalf("Expected 3 checked files, got %d", report.FilesChecked)
+	}
+	if report.UnusedExports != 0 {
+		t.Fatalf("Expected 0 unused exports, got %d", report.UnusedExports)
+	}
+	expected := []CaseMismatch{
+		{File: "./index.js", Line: 2, Path: "./Components/Button", Suggestion: "./components/button"},
+		{File: "./index.js", Line: 3, Path: "./components/icon", Suggestion: "./components/Icon"},
+	}
+	if !reflect.DeepEqual(report.CaseMismatches, expected) {
+		t.Fatalf("Expected %v, got %v", expected, report.CaseMismatches)
+	}
+}
+
+func TestEngineWithImportMap(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/importmap.json": `{"imports": {"utils/": "./lib/utils/"}}`,
+		"/projectA/index.js": `
+import { format } from 'utils/strings'
+import React from 'react'
+`,
+		"/projectA/lib/utils/strings.js": "export function format() {\n}\nexport function unused() {\n}",
+	}
+	memload := loaders.NewMemLoader(fileset)
+	imap, err := LoadImportMap(memload, "/projectA/importmap.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ng := New("/projectA/index.js", memload, WithResolver(imap))
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesChecked != 2 {
+		t.Fatalf("Expected 2 checked files, got %d", report.FilesChecked)
+	}
+	if report.UnusedExports != 1 {
+		t.Fatalf("Expected 1 unused export, got %d", report.UnusedExports)
+	}
+}
+
+func TestEngineWithDeclarationFiles(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.ts": `
+import { greet } from './greeter'
+`,
+		"/projectA/greeter.d.ts": `
+export declare function greet(name: string): string;
+export declare function farewell(name: string): string;
+`,
+		"/projectA/greeter.js": `
+import { format } from './format'
+
+export function greet(name) {
+	return format('Hello', name)
+}
+
+export function shout(name) {
+	return format('HEY', name)
+}
+`,
+		"/projectA/format.js": `
+export function format(greeting, name) {
+	return greeting + ', ' + name
+}
+`,
+	}
+	memload := loaders.NewMemLoader(fileset)
+	ng := New("/projectA/index.ts", memload)
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesChecked != 4 {
+		t.Fatalf("Expected 4 checked files, got %d", report.FilesChecked)
+	}
+	// Both farewell and shout are unused, while greet is used through its declaration.
+	if report.UnusedExports != 2 {
+		t.Fatalf("Expected 2 unused exports, got %d: %q", report.UnusedExports, report.Results)
+	}
+	expected := []DeclarationMismatch{
+		{File: "./greeter.d.ts", Line: 3, Name: "farewell", Companion: "./greeter.js"},
+		{File: "./greeter.js", Line: 8, Name: "shout", Companion: "./greeter.d.ts"},
+	}
+	if !reflect.DeepEqual(report.DeclarationMismatches, expected) {
+		t.Fatalf("Expected %v, got %v", expected, report.DeclarationMismatches)
+	}
+}
+
+func TestEngineWithAmbientModules(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.ts": `/// <reference path="./types/analytics.d.ts" />
+import { track } from 'analytics'
+import logo from './logo.svg'
+import React from 'react'
+`,
+		"/projectA/types/analytics.d.ts": `
+declare module 'analytics' {
+  export function track(event: string): void;
+  export function identify(user: string): void;
+}
+`,
+		"/projectA/logo.svg": "<svg></svg>",
+	}
+	memload := loaders.NewMemLoader(fileset)
+	ng := New("/projectA/index.ts", memload)
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesChecked != 3 {
+		t.Fatalf("Expected 3 checked files, got %d", report.FilesChecked)
+	}
+	if report.UnusedExports != 1 {
+		t.Fatalf("Expected 1 unused export, got %d: %q", report.UnusedExports, report.Results)
+	}
+	if !reflect.DeepEqual(report.Externals, []string{"react"}) {
+		t.Fatalf("Expected externals %q, got %q", []string{"react"}, report.Externals)
+	}
+}
+
+func TestEngineWithExcludedFiles(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/.gitignore": "dist/\n",
+		"/projectA/index.js": `
+import { hackPentagon } from './firstFile'
+import { bundle } from './dist/bundle'
+`,
+		"/projectA/firstFile.js": `
+import { schema } from './firstFile.generated'
+
+export function hackPentagon() {
+    return 'Hacked!'
+}`,
+		"/projectA/firstFile.generated.js": `
+export const schema = {}
+export const unused = {}
+`,
+		"/projectA/dist/bundle.js": `
+export function bundle() {
+    return 'Bundled!'
+}
+export function unused() {}
+`,
+	}
+	exload := loaders.NewExcludeLoader(loaders.NewMemLoader(fileset), "/projectA", []string{"*.generated.js"}, true)
+	ng := New("/projectA/index.js", loaders.NewCachingLoader(exload))
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesChecked != 2 {
+		t.Fatalf("Expected 2 checked files, got %d", report.FilesChecked)
+	}
+	if report.UnusedExports != 0 {
+		t.Fatalf("Expected no unused exports, got %d: %q", report.UnusedExports, report.Results)
+	}
+	if len(report.Unresolved) != 0 {
+		t.Fatalf("Expected no unresolved imports, got %v", report.Unresolved)
+	}
+}
+
+func TestEngineWithMultipleEntries(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/src/index.js": `
+import { render } from './shared'
+`,
+		"/projectA/workers/worker.js": `
+import { compute } from '../src/shared'
+`,
+		"/projectA/src/shared.js": `
+export function render() {
+    return 'Rendered!'
+}
+export function compute() {
+    return 42
+}
+export function unused() {
+    return 'Unused!'
+}`,
+	}
+	ng := New("/projectA/src/index.js", loaders.NewMemLoader(fileset), WithEntries("/projectA/workers/worker.js", "/projectA/src/index"))
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesChecked != 3 {
+		t.Fatalf("Expected 3 checked files, got %d", report.FilesChecked)
+	}
+	expected := []string{"./src/shared.js:8 \"export function unused()\"\n"}
+	if !reflect.DeepEqual(report.Results, expected) {
+		t.Fatalf("Expected %q, got %q", expected, report.Results)
+	}
+	entries := []string{"/projectA/src/index.js", "/projectA/workers/worker.js"}
+	if !reflect.DeepEqual(ng.Entries(), entries) {
+		t.Fatalf("Expected entries %q, got %q", entries, ng.Entries())
+	}
+}
+
+func TestEngineWithWorkers(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": "import { module0 } from './module0'\nimport { module1 } from './Module1'\nimport { missing } from './missing'\nimport styles from './styles.module.css'\n",
+		// Classes declared on the same line must still be reported in the same order.
+		"/projectA/styles.module.css": ".a, .b, .c, .d, .e {\n\tcolor: red;\n}\n",
+	}
+	for i := 0; i < 200; i++ {
+		fileset[fmt.Sprintf("/projectA/module%d.js", i)] = fmt.Sprintf(
+			"import { module%d } from './module%d'\nimport { module%d } from './module%d'\n\nexport function module%d() {\n}\nexport function unused%d() {\n}\n",
+			(i*2+1)%200, (i*2+1)%200, (i*2+2)%200, (i*2+2)%200, i, i,
+		)
+	}
+
+	reports := make([]Report, 0, 4)
+	for _, workers := range []int{1, 8, 1, 8} {
+		ng := New("/projectA/index.js", loaders.NewMemLoader(fileset), WithWorkers(workers), WithUnresolvedPolicy(UnresolvedWarn))
+		report, err := ng.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.FilesChecked != 202 {
+			t.Fatalf("Expected 202 checked files, got %d", report.FilesChecked)
+		}
+		if report.UnusedExports != 205 {
+			t.Fatalf("Expected 205 unused exports, got %d", report.UnusedExports)
+		}
+		reports = append(reports, report)
+	}
+	for _, report := range reports[1:] {
+		if !reflect.DeepEqual(reports[0], report) {
+			t.Fatalf("Expected the same report regardless of the number of workers, got %v and %v", reports[0], report)
+		}
+	}
+}
+
+// cancelLoader is a SourceLoader that cancels a context once it has loaded a given file.
+type cancelLoader struct {
+	SourceLoader
+	fname  string
+	cancel context.CancelFunc
+}
+
+func (cl cancelLoader) Load(fname string) (io.ReadCloser, error) {
+	if fname == cl.fname {
+		cl.cancel()
+	}
+	return cl.SourceLoader.Load(fname)
+}
+
+func TestEngineWithCancelledContext(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": `
+import { hackPentagon } from './firstFile'
+`,
+		"/projectA/firstFile.js": `
+import { hackNSA } from './secondFile'
+
+export function hackPentagon() {
+    return 'Hacked!'
+}`,
+		"/projectA/secondFile.js": `
+export function hackNSA() {
+    return 'Hacked!'
+}`,
+	}
+
+	t.Run("before starting", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		ng := New("/projectA/index.js", loaders.NewMemLoader(fileset))
+		report, err := ng.StartContext(ctx)
+		if err != context.Canceled {
+			t.Fatalf("Expected %v, got %v", context.Canceled, err)
+		}
+		if report.FilesChecked != 0 {
+			t.Fatalf("Expected 0 checked files, got %d", report.FilesChecked)
+		}
+	})
+
+	t.Run("while running", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		loader := cancelLoader{SourceLoader: loaders.NewMemLoader(fileset), fname: "/projectA/firstFile.js", cancel: cancel}
+		ng := New("/projectA/index.js", loader, WithWorkers(1))
+		report, err := ng.StartContext(ctx)
+		if err != context.Canceled {
+			t.Fatalf("Expected %v, got %v", context.Canceled, err)
+		}
+		if report.FilesChecked == 0 || report.FilesChecked == 3 {
+			t.Fatalf("Expected a partial report, got %d checked files", report.FilesChecked)
+		}
+	})
+}
+
+func TestEngineWithProgress(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": `
+import { hackPentagon } from './firstFile'
+`,
+		"/projectA/firstFile.js": `
+import { hackNSA } from './secondFile'
+
+export function hackPentagon() {
+    return 'Hacked!'
+}`,
+		"/projectA/secondFile.js": `
+export function hackNSA() {
+    return 'Hacked!'
+}`,
+	}
+
+	events := make([]Event, 0, 10)
+	ng := New("/projectA/index.js", loaders.NewMemLoader(fileset), WithTransitive(), WithProgress(func(ev Event) {
+		events = append(events, ev)
+	}))
+	if _, err := ng.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []Event{
+		{Kind: EventPhase, Phase: PhaseTraverse},
+		{Kind: EventDiscovered, Phase: PhaseTraverse, File: "/projectA/index.js", Discovered: 1, Queued: 1},
+		{Kind: EventParsed, Phase: PhaseTraverse, File: "/projectA/index.js", Discovered: 1, Parsed: 1},
+		{Kind: EventDiscovered, Phase: PhaseTraverse, File: "/projectA/firstFile.js", Discovered: 2, Parsed: 1, Queued: 1},
+		{Kind: EventParsed, Phase: PhaseTraverse, File: "/projectA/firstFile.js", Discovered: 2, Parsed: 2},
+		{Kind: EventDiscovered, Phase: PhaseTraverse, File: "/projectA/secondFile.js", Discovered: 3, Parsed: 2, Queued: 1},
+		{Kind: EventParsed, Phase: PhaseTraverse, File: "/projectA/secondFile.js", Discovered: 3, Parsed: 3},
+		{Kind: EventPhase, Phase: PhaseRefCounts, Discovered: 3, Parsed: 3},
+		{Kind: EventPhase, Phase: PhaseLiveness, Discovered: 3, Parsed: 3},
+		{Kind: EventPhase, Phase: PhaseDone, Discovered: 3, Parsed: 3},
+	}
+	if !reflect.DeepEqual(events, expected) {
+		t.Fatalf("Expected events %+v, got %+v", expected, events)
+	}
+
+	t.Run("when the analysis fails", func(t *testing.T) {
+		events = events[:0]
+		fileset["/projectA/secondFile.js"] = `
+import { hackCIA } from './missingFile'
+`
+		ng := New("/projectA/index.js", loaders.NewMemLoader(fileset), WithProgress(func(ev Event) {
+			events = append(events, ev)
+		}))
+		if _, err := ng.Start(); err == nil {
+			t.Fatal("Expected an error for the unresolved import")
+		}
+		if last := events[len(events)-1]; last.Kind != EventPhase || last.Phase != PhaseDone {
+			t.Fatalf("Expected the last event to enter %v, got %+v", PhaseDone, last)
+		}
+	})
+}
@@ -0,0 +1,199 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mkock/esclean/script"
+)
+
+// routeDirs are the directories that frameworks with file system based routing, such as Next.js, load
+// pages from. Every script in a pages directory is an entry file.
+var routeDirs = []string{"pages", "src/pages"}
+
+// appDirs are the directories that frameworks with an app router, such as Next.js, load routes from.
+// Only scripts with one of the names in appFiles are entry files; the rest are regular modules.
+var appDirs = []string{"app", "src/app"}
+
+// appFiles are the base names of the special files of an app router.
+var appFiles = map[string]bool{
+	"page": true, "layout": true, "template": true, "loading": true, "error": true, "global-error": true,
+	"not-found": true, "default": true, "route": true,
+}
+
+// DiscoverEntries returns the entry files of the project in dir. They are read from the main, module, bin,
+// exports and scripts fields of its package.json file, and from the pages/ and app/ directories of frameworks
+// with file system based routing. Bundler configs aren't read. Only files that the loader resolves, and doesn't
+// exclude, are returned.
+// Returns an error if no entry files are found.
+func DiscoverEntries(loader SourceLoader, dir string) ([]string, error) {
+	entries := make([]string, 0, 10)
+	seen := make(map[string]bool)
+	add := func(fname string) {
+		res := loader.Resolve(fname)
+		if res == "" || seen[res] || strings.Contains(res, "/node_modules/") {
+			return
+		}
+		if ex, ok := loader.(Excluder); ok && ex.Excluded(res) {
+			return
+		}
+		seen[res] = true
+		entries = append(entries, res)
+	}
+
+	if manifest := loader.Resolve(filepath.Join(dir, "package.json")); manifest != "" {
+		var pkg packageManifest
+		if err := loadJSON(loader, manifest, &pkg); err != nil {
+			return nil, err
+		}
+		for _, fname := range pkg.entries() {
+			add(filepath.Join(dir, fname))
+		}
+	}
+
+	if dr, ok := loader.(DirReader); ok {
+		for _, routeDir := range routeDirs {
+			for _, fname := range scriptsIn(dr, filepath.Join(dir, routeDir)) {
+				add(fname)
+			}
+		}
+		for _, appDir := range appDirs {
+			for _, fname := range scriptsIn(dr, filepath.Join(dir, appDir)) {
+				base := filepath.Base(fname)
+				if appFiles[base[:strings.IndexByte(base, '.')]] {
+					add(fname)
+				}
+			}
+		}
+	}
+
+	if len(entries) == 0 {
+		return nil, fmt.Errorf("no entry files found in %q", dir)
+	}
+	return entries, nil
+}
+
+// A packageManifest is a package.json file, including the fields that refer to the entry files of a project.
+type packageManifest struct {
+	packageJSON
+	Bin     json.RawMessage   `json:"bin"`
+	Scripts map[string]string `json:"scripts"`
+}
+
+// entries returns the paths of the files that the package.json file refers to as entry files, relative to
+// the package directory, in the order of the main, module, bin, exports and scripts fields.
+func (pkg *packageManifest) entries() []string {
+	fnames := make([]string, 0, 10)
+	if pkg.Main != "" {
+		fnames = append(fnames, pkg.Main)
+	}
+	if pkg.Module != "" {
+		fnames = append(fnames, pkg.Module)
+	}
+
+	// bin is either a single path, or a map of command names to paths.
+	var bin string
+	bins := make(map[string]string)
+	if err := json.Unmarshal(pkg.Bin, &bin); err == nil && bin != "" {
+		fnames = append(fnames, bin)
+	} else if err = json.Unmarshal(pkg.Bin, &bins); err == nil {
+		names := make([]string, 0, len(bins))
+		for name := range bins {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		for _, name := range names {
+			fnames = append(fnames, bins[name])
+		}
+	}
+
+	fnames = append(fnames, allExportPaths(pkg.Exports)...)
+
+	names := make([]string, 0, len(pkg.Scripts))
+	for name := range pkg.Scripts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		fnames = append(fnames, scriptPaths(pkg.Scripts[name])...)
+	}
+
+	return fnames
+}
+
+// allExportPaths returns the file paths that the "exports" field of a package.json file refers to, including
+// those of sub path exports, ie. "./utils". Sub paths are sorted, and so are the paths of each sub path, with
+// "import" conditions first. Sub path patterns, ie. "./*", are skipped.
+func allExportPaths(exports json.RawMessage) []string {
+	var subpaths map[string]json.RawMessage
+	if err := json.Unmarshal(exports, &subpaths); err != nil {
+		return exportPaths(exports)
+	}
+
+	keys := make([]string, 0, len(subpaths))
+	for key := range subpaths {
+		if !strings.HasPrefix(key, ".") {
+			// The exports are conditions of the main entry rather than sub paths.
+			return exportPaths(exports)
+		}
+		if !strings.Contains(key, "*") {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	paths := make([]string, 0, len(keys))
+	for _, key := range keys {
+		paths = append(paths, exportPaths(subpaths[key])...)
+	}
+	return paths
+}
+
+// scriptPaths returns the arguments of the shell command cmd that look like paths to scripts,
+// ie. "src/cli.ts" in "ts-node src/cli.ts --watch". The values of options are skipped, and so are config files,
+// ie. "webpack.config.js" in "webpack --config webpack.config.js", since bundler configs aren't read.
+func scriptPaths(cmd string) []string {
+	fnames := make([]string, 0, 1)
+	var skipNext bool
+	for _, arg := range strings.Fields(cmd) {
+		arg = strings.Trim(arg, `'"`)
+		if skipNext {
+			skipNext = false
+			continue
+		}
+		if arg == "-c" || arg == "--config" {
+			skipNext = true
+			continue
+		}
+		if strings.HasPrefix(arg, "-") || strings.Contains(arg, "://") || strings.Contains(filepath.Base(arg), ".config.") ||
+			filepath.Ext(arg) == "" || !script.IsScript(arg) {
+			continue
+		}
+		fnames = append(fnames, arg)
+	}
+	return fnames
+}
+
+// scriptsIn returns the paths of the scripts in dir and its subdirectories, sorted by name. Declaration files
+// aren't included. Returns nil if dir doesn't exist.
+func scriptsIn(dr DirReader, dir string) []string {
+	entries, err := dr.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	fnames := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		fname := filepath.Join(dir, entry.Name())
+		switch {
+		case entry.IsDir():
+			fnames = append(fnames, scriptsIn(dr, fname)...)
+		case filepath.Ext(fname) != "" && script.IsScript(fname) && !strings.HasSuffix(fname, ".d.ts"):
+			fnames = append(fnames, fname)
+		}
+	}
+	return fnames
+}
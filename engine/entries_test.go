@@ -0,0 +1,111 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mkock/esclean/engine/loaders"
+)
+
+func TestScriptPaths(t *testing.T) {
+	cases := map[string][]string{
+		"node scripts/build.js --watch":                {"scripts/build.js"},
+		"ts-node 'src/cli.ts' && eslint .":             {"src/cli.ts"},
+		"webpack --config=webpack.config.js":           {},
+		"rollup -c rollup.config.mjs src/extra.js":     {"src/extra.js"},
+		"vite build --config vite.config.ts":           {},
+		"jest --coverage":                              {},
+		"curl https://example.com/install.js | node -": {},
+	}
+
+	for in, expected := range cases {
+		if actual := scriptPaths(in); !reflect.DeepEqual(actual, expected) {
+			t.Fatalf("scriptPaths(%q): expected %q, got %q", in, expected, actual)
+		}
+	}
+}
+
+func TestDiscoverEntries(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/package.json": `{
+  "main": "./dist/index.js",
+  "module": "./src/index",
+  "bin": {"tool": "./bin/tool.js"},
+  "exports": {".": {"import": "./src/index.ts"}, "./worker": "./src/worker.ts"},
+  "scripts": {"build": "node scripts/build.js", "test": "jest"}
+}`,
+		"/projectA/src/index.ts":                  "",
+		"/projectA/src/worker.ts":                 "",
+		"/projectA/bin/tool.js":                   "",
+		"/projectA/scripts/build.js":              "",
+		"/projectA/pages/index.tsx":               "",
+		"/projectA/pages/blog/[slug].tsx":         "",
+		"/projectA/pages/blog/types.d.ts":         "",
+		"/projectA/src/app/page.tsx":              "",
+		"/projectA/src/app/dashboard/layout.tsx":  "",
+		"/projectA/src/app/dashboard/Chart.tsx":   "",
+		"/projectA/src/app/dashboard/styles.css":  "",
+		"/projectA/node_modules/dep/package.json": "",
+	}
+	memload := loaders.NewMemLoader(fileset)
+
+	entries, err := DiscoverEntries(memload, "/projectA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"/projectA/src/index.ts",
+		"/projectA/bin/tool.js",
+		"/projectA/src/worker.ts",
+		"/projectA/scripts/build.js",
+		"/projectA/pages/blog/[slug].tsx",
+		"/projectA/pages/index.tsx",
+		"/projectA/src/app/dashboard/layout.tsx",
+		"/projectA/src/app/page.tsx",
+	}
+	if !reflect.DeepEqual(entries, expected) {
+		t.Fatalf("Expected %q, got %q", expected, entries)
+	}
+
+	if _, err = DiscoverEntries(memload, "/projectB"); err == nil {
+		t.Fatal("Expected an error for a project without entry files")
+	}
+}
+
+func TestEngineWithDiscoveredEntries(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/package.json": `{"scripts": {"dev": "next dev"}}`,
+		"/projectA/pages/index.tsx": `
+import Button from '../components/Button'
+import { Icon } from '../components/Icon'
+
+export default function Home() {
+    return <Button><Icon /></Button>
+}`,
+		"/projectA/components/Button.tsx": `
+export default function Button({ children }) {
+    return <button>{children}</button>
+}
+export function IconButton() {
+}`,
+		"/projectA/components/Icon.jsx": `
+export function Icon() {
+    return <svg />
+}`,
+	}
+	memload := loaders.NewMemLoader(fileset)
+
+	entries, err := DiscoverEntries(memload, "/projectA")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ng := New(entries[0], memload, WithEntries(entries[1:]...))
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The components are imported without their .tsx and .jsx extensions.
+	if report.FilesChecked != 3 {
+		t.Fatalf("Expected 3 checked files, got %d", report.FilesChecked)
+	}
+}
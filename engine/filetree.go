@@ -2,13 +2,14 @@ package engine
 
 import (
 	"fmt"
+	"sort"
 
 	"github.com/mkock/esclean/script"
 )
 
 // FileTree is a map of all discovered files in a project.
 // It uses the normalised path as key, which should make it easy to revisit files
-// based on the import path from the current file. Lookups are O(n).
+// based on the import path from the current file. Lookups by path are O(1).
 type FileTree map[string]*script.File
 
 // Visited returns the paths of all files visited during a source code analysis.
@@ -21,31 +22,97 @@ func (tree *FileTree) Visited() []string {
 	return files
 }
 
+// Externals returns the distinct bare import specifiers, ie. package names and URLs, that were not mapped to
+// any file in the tree. They are considered to be external leaf nodes. The specifiers are sorted.
+func (tree *FileTree) Externals() []string {
+	seen := make(map[string]bool)
+	specs := make([]string, 0, 10)
+	for _, file := range *tree {
+		for _, imp := range file.BareImports {
+			if !seen[imp.RelPath] {
+				seen[imp.RelPath] = true
+				specs = append(specs, imp.RelPath)
+			}
+		}
+	}
+	sort.Strings(specs)
+
+	return specs
+}
+
 // UpdateRefCounts traverses the file tree, and for each ImportStmt, it will attempt to match it to an ExportStmt
-// from the file that matches the file path, and if found, increment its RefCount.
+// from the file that matches the file path, and if found, increment its RefCount. Exports are looked up by their
+// file and name, so this is O(n) in the number of imports. RefCounts are reset first, so the counts are correct
+// even if the tree has changed since the last update.
 func (tree *FileTree) UpdateRefCounts() {
-	// Note: this algorithm is O(n^3), which is not so good.
-	for _, file := range *tree {
+	tree.updateRefCounts(true)
+}
 
+// updateRefCounts implements UpdateRefCounts. If warn is true, a warning is printed for each import of a file
+// that isn't part of the tree.
+func (tree *FileTree) updateRefCounts(warn bool) {
+	symbols := tree.symbols()
+
+	for _, file := range *tree {
 		for _, imp := range file.Imports {
 
 			// Look for the file that matches the import path.
-			if match, ok := (*tree)[imp.RelPath]; ok {
+			match, ok := (*tree)[imp.RelPath]
+			if !ok {
+				if warn {
+					fmt.Printf("Warning: unmatched path: %q\n", imp.RelPath)
+				}
+				continue
+			}
 
-				// Find the matching export and increment its RefCount.
+			// Namespace imports match every export of the file.
+			if imp.Namespace != "" {
 				for _, exp := range match.Exports {
-					if exp.Matches(imp) {
-						exp.RefCount++
-					}
+					exp.RefCount++
 				}
+				continue
+			}
 
-			} else {
+			// Find the matching exports and increment their RefCounts. Default imports may match a default
+			// export by name as well.
+			countRefs(symbols[symbolKey{imp.RelPath, imp.Name}], imp)
+			if imp.Default && imp.Name != "default" {
+				countRefs(symbols[symbolKey{imp.RelPath, "default"}], imp)
+			}
+		}
+	}
+}
 
-				fmt.Printf("Warning: unmatched path: %q\n", imp.RelPath)
+// countRefs increments the RefCount of each of the given exports that matches imp.
+func countRefs(exps []*script.ExportStmt, imp *script.ImportStmt) {
+	for _, exp := range exps {
+		if exp.Matches(imp) {
+			exp.RefCount++
+		}
+	}
+}
 
-			}
+// A symbolKey identifies a symbol by the path of the file that exports it and its name.
+type symbolKey struct {
+	file, name string
+}
+
+// symbols resets the RefCount of every ExportStmt in the tree and returns an index of them by file and name.
+// Most keys belong to a single export, unless a function is overloaded.
+func (tree *FileTree) symbols() map[symbolKey][]*script.ExportStmt {
+	var n int
+	for _, file := range *tree {
+		n += len(file.Exports)
+	}
+	symbols := make(map[symbolKey][]*script.ExportStmt, n)
+	for fname, file := range *tree {
+		for _, exp := range file.Exports {
+			exp.RefCount = 0
+			key := symbolKey{fname, exp.Name}
+			symbols[key] = append(symbols[key], exp)
 		}
 	}
+	return symbols
 }
 
 // FindExports returns a slice of all ExportStmts that match the given refCount.
@@ -1,6 +1,7 @@
 package engine
 
 import (
+	"fmt"
 	"testing"
 
 	set "github.com/deckarep/golang-set"
@@ -131,6 +132,120 @@ func TestFindExports(t *testing.T) {
 	}
 
 	if !set.NewSetFromSlice(actualIf).Equal(set.NewSetFromSlice(expected)) {
-		t.Fatalf("Expected %q, got %q", expected, actual)
+		t.Fatalf("Expected %q, got %q", expected, actualIf)
+	}
+}
+
+// naiveUpdateRefCounts is the original O(n^3) implementation of FileTree.UpdateRefCounts, which scans every
+// export of the imported file for each import. It is kept for comparison.
+func naiveUpdateRefCounts(tree *FileTree) {
+	for _, file := range *tree {
+		for _, exp := range file.Exports {
+			exp.RefCount = 0
+		}
+	}
+	for _, file := range *tree {
+		for _, imp := range file.Imports {
+			if match, ok := (*tree)[imp.RelPath]; ok {
+				for _, exp := range match.Exports {
+					if exp.Matches(imp) {
+						exp.RefCount++
+					}
+				}
+			}
+		}
+	}
+}
+
+// syntheticTree returns a FileTree of n files with 10 imports each. Like in real projects, a few files are shared
+// modules, ie. barrel files or generated API clients: every hundredth file has 1,000 exports rather than 10, and half
+// of the imports refer to one of them. Every seventh import is a default import and, if namespaces is true, every
+// fifth is a namespace import.
+func syntheticTree(n int, namespaces bool) *FileTree {
+	tree := make(FileTree, n)
+	for i := 0; i < n; i++ {
+		fname := fmt.Sprintf("/project/src/module%d.ts", i)
+		file := script.NewFile(fname)
+		exports := 10
+		if i%100 == 0 {
+			exports = 1000
+		}
+		for j := 0; j < exports; j++ {
+			exp := &script.ExportStmt{FileRef: file, Line: j + 1, Name: fmt.Sprintf("func%d", j), Signature: fmt.Sprintf("export function func%d()", j)}
+			if j == 0 {
+				exp.Name, exp.Signature = "default", "export default"
+			}
+			file.Exports[exp.Hash(fname)] = exp
+		}
+		for j := 0; j < 10; j++ {
+			target := (i*7 + j) % n
+			if j%2 == 0 {
+				target -= target % 100
+			}
+			imp := &script.ImportStmt{FileRef: file, Line: j + 1, Name: fmt.Sprintf("func%d", (i+j)%13), RelPath: fmt.Sprintf("/project/src/module%d.ts", target)}
+			switch {
+			case namespaces && j%5 == 0:
+				imp.Name, imp.Namespace = "*", "ns"
+			case j%7 == 0:
+				imp.Default = true
+			}
+			file.Imports[imp.Hash(fname)] = imp
+		}
+		tree[fname] = file
+	}
+	return &tree
+}
+
+func TestUpdateRefCountsMatchesNaive(t *testing.T) {
+	tree := syntheticTree(1000, true)
+	naiveUpdateRefCounts(tree)
+	expected := make(map[*script.ExportStmt]int)
+	for _, file := range *tree {
+		for _, exp := range file.Exports {
+			expected[exp] = exp.RefCount
+		}
+	}
+
+	// Running it twice should give the same result, since RefCounts are reset.
+	tree.UpdateRefCounts()
+	tree.UpdateRefCounts()
+	for exp, refCount := range expected {
+		if exp.RefCount != refCount {
+			t.Fatalf("Expected RefCount for %s:%s to be %d, got %d", exp.FileRef.RelPath, exp.Name, refCount, exp.RefCount)
+		}
+	}
+}
+
+// Namespace imports match every export of the imported file either way, so the named imports are benchmarked
+// separately to measure the lookup of exports.
+func BenchmarkUpdateRefCounts(b *testing.B) {
+	tree := syntheticTree(50000, false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.UpdateRefCounts()
+	}
+}
+
+func BenchmarkNaiveUpdateRefCounts(b *testing.B) {
+	tree := syntheticTree(50000, false)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		naiveUpdateRefCounts(tree)
+	}
+}
+
+func BenchmarkUpdateRefCountsWithNamespaces(b *testing.B) {
+	tree := syntheticTree(50000, true)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		tree.UpdateRefCounts()
+	}
+}
+
+func BenchmarkNaiveUpdateRefCountsWithNamespaces(b *testing.B) {
+	tree := syntheticTree(50000, true)
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		naiveUpdateRefCounts(tree)
 	}
 }
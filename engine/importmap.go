@@ -0,0 +1,92 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// An ImportMap maps bare import specifiers to files, following the WICG import maps specification
+// (https://github.com/WICG/import-maps). Addresses are resolved relative to the directory that contains the
+// import map, and that directory is also considered to be the root for addresses starting with "/".
+// Only addresses of local files are supported; URLs are ignored.
+type ImportMap struct {
+	Imports map[string]string            `json:"imports"`
+	Scopes  map[string]map[string]string `json:"scopes"`
+	dir     string
+	scopes  []string // Scope prefixes, most specific first.
+}
+
+// LoadImportMap reads and returns the import map in the file fname, using loader.
+func LoadImportMap(loader SourceLoader, fname string) (*ImportMap, error) {
+	rc, err := loader.Load(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var imap ImportMap
+	if err = json.NewDecoder(rc).Decode(&imap); err != nil {
+		return nil, fmt.Errorf("unable to read import map %q: %s", fname, err)
+	}
+	imap.dir = filepath.Dir(fname)
+
+	imap.scopes = make([]string, 0, len(imap.Scopes))
+	for scope := range imap.Scopes {
+		imap.scopes = append(imap.scopes, scope)
+	}
+	sort.Slice(imap.scopes, func(i, j int) bool {
+		return len(imap.address(imap.scopes[i])) > len(imap.address(imap.scopes[j]))
+	})
+
+	return &imap, nil
+}
+
+// ResolveSpecifier returns the path of the file that spec is mapped to when imported by the file importer.
+// Scopes that match importer take precedence over the top-level imports.
+func (imap *ImportMap) ResolveSpecifier(spec, importer string) string {
+	for _, scope := range imap.scopes {
+		prefix := imap.address(scope)
+		if prefix == "" || !(importer == prefix || strings.HasSuffix(scope, "/") && strings.HasPrefix(importer, prefix+string(filepath.Separator))) {
+			continue
+		}
+		if res := imap.match(imap.Scopes[scope], spec); res != "" {
+			return res
+		}
+	}
+	return imap.match(imap.Imports, spec)
+}
+
+// match returns the path of the file that spec is mapped to in the given specifier map.
+// Keys ending with "/" match any specifier that they are a prefix of; the longest key wins.
+func (imap *ImportMap) match(specMap map[string]string, spec string) string {
+	if addr, ok := specMap[spec]; ok {
+		return imap.address(addr)
+	}
+
+	var best string
+	for key := range specMap {
+		if strings.HasSuffix(key, "/") && strings.HasPrefix(spec, key) && len(key) > len(best) {
+			best = key
+		}
+	}
+	if best == "" || !strings.HasSuffix(specMap[best], "/") {
+		return ""
+	}
+	base := imap.address(specMap[best])
+	if base == "" {
+		return ""
+	}
+	return filepath.Join(base, filepath.FromSlash(spec[len(best):]))
+}
+
+// address returns the path of the file that the import map address addr refers to.
+// Returns an empty string if addr isn't a local path.
+func (imap *ImportMap) address(addr string) string {
+	if strings.HasPrefix(addr, "./") || strings.HasPrefix(addr, "../") || strings.HasPrefix(addr, "/") {
+		return filepath.Join(imap.dir, filepath.FromSlash(addr))
+	}
+	return ""
+}
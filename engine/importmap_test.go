@@ -0,0 +1,45 @@
+package engine
+
+import (
+	"testing"
+
+	"github.com/mkock/esclean/engine/loaders"
+)
+
+func TestImportMap(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/importmap.json": `{
+	"imports": {
+		"moment": "/vendor/moment.js",
+		"lodash/": "./vendor/lodash/",
+		"lodash/fp/": "./vendor/lodash-fp/",
+		"react": "https://esm.sh/react"
+	},
+	"scopes": {
+		"/legacy/": {
+			"moment": "/vendor/moment-legacy.js"
+		}
+	}
+}`,
+	}
+	imap, err := LoadImportMap(loaders.NewMemLoader(fileset), "/projectA/importmap.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		spec, importer, expected string
+	}{
+		{"moment", "/projectA/index.js", "/projectA/vendor/moment.js"},
+		{"moment", "/projectA/legacy/index.js", "/projectA/vendor/moment-legacy.js"},
+		{"lodash/map", "/projectA/index.js", "/projectA/vendor/lodash/map"},
+		{"lodash/fp/map", "/projectA/index.js", "/projectA/vendor/lodash-fp/map"},
+		{"react", "/projectA/index.js", ""}, // URLs aren't supported.
+		{"vue", "/projectA/index.js", ""},
+	}
+	for _, c := range cases {
+		if actual := imap.ResolveSpecifier(c.spec, c.importer); actual != c.expected {
+			t.Fatalf("ResolveSpecifier(%q, %q): expected %q, got %q", c.spec, c.importer, c.expected, actual)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package engine
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/mkock/esclean/script"
+)
+
+// A DeadExport is an export that is used, but only by code that is unreachable from the entry files itself.
+// Chain contains the declarations that keep it alive in the RefCounts, ie. the export that uses it, the export
+// that uses that one and so on, ending with an unused export or import.
+type DeadExport struct {
+	File      string
+	Line      int
+	Signature string
+	Chain     []string
+}
+
+// liveness marks the files, imports and exports that are reachable from the entry files.
+// An import is reachable if its file is reachable and it is used by top-level code or by a reachable export,
+// and an export is reachable if a reachable import refers to it, or if it belongs to an entry file.
+type liveness struct {
+	ng      *Engine
+	files   map[string]bool
+	imports map[*script.ImportStmt]bool
+	exports map[*script.ExportStmt]bool
+	// Work lists.
+	pendingFiles   []string
+	pendingImports []*script.ImportStmt
+	pendingExports []*script.ExportStmt
+}
+
+// findDeadExports returns the exports that have a RefCount, but are unreachable from the entry files,
+// sorted by file and line.
+func (ng *Engine) findDeadExports() []DeadExport {
+	live := liveness{
+		ng:      ng,
+		files:   make(map[string]bool, len(ng.tree)),
+		imports: make(map[*script.ImportStmt]bool),
+		exports: make(map[*script.ExportStmt]bool),
+	}
+	live.pendingFiles = append(live.pendingFiles, ng.entries...)
+	live.propagate()
+
+	importers := ng.importers()
+	dead := make([]DeadExport, 0)
+	for _, file := range ng.tree {
+		for _, exp := range file.Exports {
+			if exp.RefCount == 0 || live.exports[exp] || ng.isPackageExport(exp) {
+				continue
+			}
+			dead = append(dead, DeadExport{
+				File:      ng.relPath(file.RelPath),
+				Line:      exp.Line,
+				Signature: exp.Signature,
+				Chain:     ng.deadChain(exp, importers),
+			})
+		}
+	}
+	sort.Slice(dead, func(i, j int) bool {
+		if dead[i].File != dead[j].File {
+			return dead[i].File < dead[j].File
+		}
+		if dead[i].Line != dead[j].Line {
+			return dead[i].Line < dead[j].Line
+		}
+		return dead[i].Signature < dead[j].Signature
+	})
+
+	return dead
+}
+
+// propagate processes the work lists until everything that is reachable has been marked.
+func (live *liveness) propagate() {
+	for len(live.pendingFiles)+len(live.pendingImports)+len(live.pendingExports) > 0 {
+		switch {
+		case len(live.pendingFiles) > 0:
+			fname := live.pendingFiles[len(live.pendingFiles)-1]
+			live.pendingFiles = live.pendingFiles[:len(live.pendingFiles)-1]
+			live.markFile(fname)
+		case len(live.pendingImports) > 0:
+			imp := live.pendingImports[len(live.pendingImports)-1]
+			live.pendingImports = live.pendingImports[:len(live.pendingImports)-1]
+			live.markImport(imp)
+		default:
+			exp := live.pendingExports[len(live.pendingExports)-1]
+			live.pendingExports = live.pendingExports[:len(live.pendingExports)-1]
+			live.markExport(exp)
+		}
+	}
+}
+
+// markFile marks the file fname as reachable, along with the imports of its top-level code.
+func (live *liveness) markFile(fname string) {
+	file, ok := live.ng.tree[fname]
+	if !ok || live.files[fname] {
+		return
+	}
+	live.files[fname] = true
+
+	for _, imp := range file.Imports {
+		if isTopLevel(imp) {
+			live.pendingImports = append(live.pendingImports, imp)
+		}
+	}
+	for _, ref := range file.References {
+		live.pendingFiles = append(live.pendingFiles, ref.RelPath)
+	}
+	if companion, ok := live.ng.companions[fname]; ok {
+		live.pendingFiles = append(live.pendingFiles, companion)
+	}
+
+	// The exports of the entry files are used by whatever runs them.
+	for _, entry := range live.ng.entries {
+		if entry != fname {
+			continue
+		}
+		for _, exp := range file.Exports {
+			live.pendingExports = append(live.pendingExports, exp)
+		}
+	}
+}
+
+// markImport marks imp as reachable, along with the file and exports that it refers to.
+func (live *liveness) markImport(imp *script.ImportStmt) {
+	if live.imports[imp] {
+		return
+	}
+	live.imports[imp] = true
+
+	file, ok := live.ng.tree[imp.RelPath]
+	if !ok {
+		return
+	}
+	live.pendingFiles = append(live.pendingFiles, imp.RelPath)
+	for _, exp := range file.Exports {
+		if exp.Matches(imp) {
+			live.pendingExports = append(live.pendingExports, exp)
+		}
+	}
+}
+
+// markExport marks exp as reachable, along with the imports that it uses. The same export of a companion
+// declaration file or implementation is reachable as well.
+func (live *liveness) markExport(exp *script.ExportStmt) {
+	if live.exports[exp] {
+		return
+	}
+	live.exports[exp] = true
+
+	file := exp.FileRef
+	for _, imp := range file.Imports {
+		if exp.UsesLocal(imp.Local) {
+			live.pendingImports = append(live.pendingImports, imp)
+		}
+	}
+	if companion, ok := live.ng.tree[live.ng.companions[file.RelPath]]; ok {
+		for _, other := range companion.Exports {
+			if other.Name == exp.Name {
+				live.pendingExports = append(live.pendingExports, other)
+			}
+		}
+	}
+}
+
+// importers returns the import statements of the FileTree by the path of the file that they import,
+// sorted by importing file, line and name.
+func (ng *Engine) importers() map[string][]*script.ImportStmt {
+	importers := make(map[string][]*script.ImportStmt)
+	for _, file := range ng.tree {
+		for _, imp := range file.Imports {
+			importers[imp.RelPath] = append(importers[imp.RelPath], imp)
+		}
+	}
+	for _, imps := range importers {
+		sort.Slice(imps, func(i, j int) bool {
+			if imps[i].FileRef.RelPath != imps[j].FileRef.RelPath {
+				return imps[i].FileRef.RelPath < imps[j].FileRef.RelPath
+			}
+			if imps[i].Line != imps[j].Line {
+				return imps[i].Line < imps[j].Line
+			}
+			return imps[i].Name < imps[j].Name
+		})
+	}
+	return importers
+}
+
+// deadChain follows the users of the unreachable export exp until it reaches an unused export or import, or a
+// file that is imported for its top-level code. Each step is formatted like Report.Results.
+func (ng *Engine) deadChain(exp *script.ExportStmt, importers map[string][]*script.ImportStmt) []string {
+	chain := make([]string, 0, 3)
+	seen := map[interface{}]bool{exp: true}
+
+	imp := consumerOf(exp, importers)
+	for imp != nil {
+		owner := ownerOf(imp)
+		switch {
+		case isTopLevel(imp):
+			// The import is used by the top-level code of a file that is only imported by dead code.
+			fname := imp.FileRef.RelPath
+			chain = append(chain, ng.relPath(fname))
+			if seen[fname] || len(importers[fname]) == 0 {
+				return chain
+			}
+			seen[fname] = true
+			imp = importers[fname][0]
+		case owner != nil:
+			chain = append(chain, fmt.Sprintf("%s:%d %q", ng.relPath(owner.FileRef.RelPath), owner.Line, owner.Signature))
+			if seen[owner] || owner.RefCount == 0 {
+				return chain
+			}
+			seen[owner] = true
+			imp = consumerOf(owner, importers)
+		default:
+			chain = append(chain, fmt.Sprintf("%s:%d (unused import)", ng.relPath(imp.FileRef.RelPath), imp.Line))
+			return chain
+		}
+	}
+
+	return chain
+}
+
+// consumerOf returns the first import statement that refers to exp, or nil if there is none.
+func consumerOf(exp *script.ExportStmt, importers map[string][]*script.ImportStmt) *script.ImportStmt {
+	for _, imp := range importers[exp.FileRef.RelPath] {
+		if exp.Matches(imp) {
+			return imp
+		}
+	}
+	return nil
+}
+
+// ownerOf returns the first export of the importing file that uses imp, or nil if there is none.
+func ownerOf(imp *script.ImportStmt) *script.ExportStmt {
+	var owner *script.ExportStmt
+	for _, exp := range imp.FileRef.Exports {
+		if exp.UsesLocal(imp.Local) && (owner == nil || exp.Line < owner.Line) {
+			owner = exp
+		}
+	}
+	return owner
+}
+
+// isTopLevel returns true if imp is used by the top-level code of its file, or if its usage is unknown.
+func isTopLevel(imp *script.ImportStmt) bool {
+	return imp.TopLevel || imp.Local == ""
+}
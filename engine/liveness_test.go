@@ -0,0 +1,77 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mkock/esclean/engine/loaders"
+)
+
+func TestEngineWithTransitive(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": `
+import { render } from './ui'
+import { legacyRender } from './legacy'
+
+render()
+`,
+		"/projectA/ui.js": `
+import { format } from './format'
+
+export function render() {
+    return format('Rendered!')
+}
+`,
+		"/projectA/legacy.js": `
+import { pad } from './format'
+import { setup } from './setup'
+
+export function legacyRender() {
+    return pad('Legacy!')
+}
+
+export function oldRender() {
+    return setup()
+}
+`,
+		"/projectA/setup.js": `
+import { connect } from './db'
+
+connect()
+
+export function setup() {
+    return true
+}
+`,
+		"/projectA/db.js": `
+export function connect() {
+    return true
+}
+`,
+		"/projectA/format.js": `
+export function format(value) {
+    return value
+}
+
+export function pad(value) {
+    return ' ' + value
+}
+`,
+	}
+	ng := New("/projectA/index.js", loaders.NewMemLoader(fileset), WithTransitive())
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// legacyRender is imported, but never used by the index file.
+	expected := []DeadExport{
+		{File: "./db.js", Line: 2, Signature: "export function connect()", Chain: []string{"./setup.js", `./legacy.js:9 "export function oldRender()"`}},
+		{File: "./format.js", Line: 6, Signature: "export function pad(value)", Chain: []string{`./legacy.js:5 "export function legacyRender()"`, "./index.js:3 (unused import)"}},
+		{File: "./legacy.js", Line: 5, Signature: "export function legacyRender()", Chain: []string{"./index.js:3 (unused import)"}},
+		{File: "./setup.js", Line: 6, Signature: "export function setup()", Chain: []string{`./legacy.js:9 "export function oldRender()"`}},
+	}
+	if !reflect.DeepEqual(report.DeadExports, expected) {
+		t.Fatalf("Expected %v, got %v", expected, report.DeadExports)
+	}
+}
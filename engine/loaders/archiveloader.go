@@ -0,0 +1,88 @@
+package loaders
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+)
+
+// ArchiveLoader serves file contents straight from a .zip, .tar, .tar.gz or .tgz archive without extracting it.
+// Files in the archive are served as if the archive had been extracted to "/".
+type ArchiveLoader struct {
+	*FSLoader
+	closer io.Closer
+}
+
+// OpenArchive opens the archive with the given file name and returns a new SourceLoader that serves its contents.
+// The archive format is determined by the file extension. Remember to call Close when done.
+func OpenArchive(fname string) (*ArchiveLoader, error) {
+	switch {
+	case strings.HasSuffix(fname, ".zip"):
+		zr, err := zip.OpenReader(fname)
+		if err != nil {
+			return nil, err
+		}
+		return &ArchiveLoader{FSLoader: NewFSLoader(zr, "/"), closer: zr}, nil
+	case strings.HasSuffix(fname, ".tar.gz"), strings.HasSuffix(fname, ".tgz"):
+		return openTar(fname, true)
+	case strings.HasSuffix(fname, ".tar"):
+		return openTar(fname, false)
+	}
+	return nil, fmt.Errorf("unsupported archive format: %q", fname)
+}
+
+// openTar reads all regular files of a (optionally gzipped) tarball into memory.
+// Tarballs cannot be read randomly, so this is the only way to serve them without extracting them to disk.
+func openTar(fname string, gzipped bool) (*ArchiveLoader, error) {
+	f, err := os.Open(fname)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var r io.Reader = f
+	if gzipped {
+		gzr, err := gzip.NewReader(f)
+		if err != nil {
+			return nil, err
+		}
+		defer gzr.Close()
+		r = gzr
+	}
+
+	fsys := make(mapFS, 100)
+	tr := tar.NewReader(r)
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to read archive %q: %s", fname, err)
+		}
+		if !hdr.FileInfo().Mode().IsRegular() {
+			continue
+		}
+		data, err := ioutil.ReadAll(tr)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read %q from archive %q: %s", hdr.Name, fname, err)
+		}
+		fsys[strings.TrimPrefix(path.Clean(hdr.Name), "/")] = data
+	}
+
+	return &ArchiveLoader{FSLoader: NewFSLoader(fsys, "/")}, nil
+}
+
+// Close releases the archive.
+func (arcload *ArchiveLoader) Close() error {
+	if arcload.closer == nil {
+		return nil
+	}
+	return arcload.closer.Close()
+}
@@ -0,0 +1,120 @@
+package loaders
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var archiveFileset = map[string]string{
+	"project/index.js":     "import { hello } from './lib'",
+	"project/lib/index.js": "export function hello() {}",
+}
+
+func writeZip(t *testing.T, fname string) {
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	zw := zip.NewWriter(f)
+	for name, content := range archiveFileset {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, err = w.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = zw.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func writeTar(t *testing.T, fname string) {
+	writeTarball(t, fname, false)
+}
+
+func writeTarGz(t *testing.T, fname string) {
+	writeTarball(t, fname, true)
+}
+
+func writeTarball(t *testing.T, fname string, gzipped bool) {
+	f, err := os.Create(fname)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var w io.WriteCloser = f
+	if gzipped {
+		w = gzip.NewWriter(f)
+	}
+	tw := tar.NewWriter(w)
+	for name, content := range archiveFileset {
+		hdr := &tar.Header{Name: name, Mode: 0644, Size: int64(len(content)), Typeflag: tar.TypeReg}
+		if err = tw.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if _, err = tw.Write([]byte(content)); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err = tw.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestArchiveLoader(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esclean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	archives := map[string]func(*testing.T, string){
+		"project.zip":    writeZip,
+		"project.tar":    writeTar,
+		"project.tar.gz": writeTarGz,
+	}
+	for name, write := range archives {
+		t.Run(name, func(t *testing.T) {
+			fname := filepath.Join(dir, name)
+			write(t, fname)
+
+			arcl, err := OpenArchive(fname)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer arcl.Close()
+
+			if actual := arcl.Resolve("/project/lib"); actual != "/project/lib/index.js" {
+				t.Fatalf("Expected %q, got %q", "/project/lib/index.js", actual)
+			}
+			rc, err := arcl.Load("/project/index.js")
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer rc.Close()
+			actual, err := ioutil.ReadAll(rc)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if string(actual) != archiveFileset["project/index.js"] {
+				t.Fatalf("Expected %q, got %q", archiveFileset["project/index.js"], string(actual))
+			}
+		})
+	}
+
+	if _, err = OpenArchive(filepath.Join(dir, "project.rar")); err == nil {
+		t.Fatal("Expected an error for an unsupported archive format")
+	}
+}
@@ -0,0 +1,118 @@
+package loaders
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+)
+
+// CacheStats contains the hit/miss statistics of a CachingLoader.
+type CacheStats struct {
+	ResolveHits, ResolveMisses int
+	ReadDirHits, ReadDirMisses int
+}
+
+// dirListing is a cached result of ReadDir.
+type dirListing struct {
+	entries []fs.DirEntry
+	err     error
+}
+
+// CachingLoader decorates another loader by memoising the results of Resolve and ReadDir, which would otherwise
+// hit the underlying file system for every import statement. CachingLoader is safe for concurrent use.
+type CachingLoader struct {
+	base     Loader
+	mu       sync.Mutex
+	resolved map[string]string
+	dirs     map[string]dirListing
+	stats    CacheStats
+}
+
+// NewCachingLoader returns a new SourceLoader that caches the results of base.
+func NewCachingLoader(base Loader) *CachingLoader {
+	return &CachingLoader{
+		base:     base,
+		resolved: make(map[string]string, 100),
+		dirs:     make(map[string]dirListing, 100),
+	}
+}
+
+// Resolve returns the memoised result of resolving fname with the underlying loader.
+func (cacheload *CachingLoader) Resolve(fname string) string {
+	cacheload.mu.Lock()
+	res, ok := cacheload.resolved[fname]
+	if ok {
+		cacheload.stats.ResolveHits++
+		cacheload.mu.Unlock()
+		return res
+	}
+	cacheload.stats.ResolveMisses++
+	cacheload.mu.Unlock()
+
+	res = cacheload.base.Resolve(fname)
+
+	cacheload.mu.Lock()
+	cacheload.resolved[fname] = res
+	cacheload.mu.Unlock()
+	return res
+}
+
+// ReadDir returns the memoised directory listing of dir from the underlying loader.
+// Returns an error if the underlying loader is unable to list directories.
+func (cacheload *CachingLoader) ReadDir(dir string) ([]fs.DirEntry, error) {
+	dr, ok := cacheload.base.(dirReader)
+	if !ok {
+		return nil, fmt.Errorf("unable to list directory %q: not supported by loader", dir)
+	}
+
+	cacheload.mu.Lock()
+	listing, ok := cacheload.dirs[dir]
+	if ok {
+		cacheload.stats.ReadDirHits++
+		cacheload.mu.Unlock()
+		return listing.entries, listing.err
+	}
+	cacheload.stats.ReadDirMisses++
+	cacheload.mu.Unlock()
+
+	listing.entries, listing.err = dr.ReadDir(dir)
+
+	cacheload.mu.Lock()
+	cacheload.dirs[dir] = listing
+	cacheload.mu.Unlock()
+	return listing.entries, listing.err
+}
+
+// Load returns a reader that you can use to read from the file contents. File contents are not cached.
+func (cacheload *CachingLoader) Load(fname string) (io.ReadCloser, error) {
+	return cacheload.LoadContext(context.Background(), fname)
+}
+
+// LoadContext is like Load, but passes ctx on to the underlying loader.
+func (cacheload *CachingLoader) LoadContext(ctx context.Context, fname string) (io.ReadCloser, error) {
+	return loadContext(ctx, cacheload.base, fname)
+}
+
+// Stats returns the hit/miss statistics of the cache.
+func (cacheload *CachingLoader) Stats() CacheStats {
+	cacheload.mu.Lock()
+	defer cacheload.mu.Unlock()
+	return cacheload.stats
+}
+
+// Reset clears the cache, ie. after files have been added or removed from the underlying file system.
+// Statistics are kept.
+func (cacheload *CachingLoader) Reset() {
+	cacheload.mu.Lock()
+	defer cacheload.mu.Unlock()
+	cacheload.resolved = make(map[string]string, 100)
+	cacheload.dirs = make(map[string]dirListing, 100)
+}
+
+// Excluded returns true if the underlying loader excludes fname.
+func (cacheload *CachingLoader) Excluded(fname string) bool {
+	ex, ok := cacheload.base.(excluder)
+	return ok && ex.Excluded(fname)
+}
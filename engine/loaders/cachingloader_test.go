@@ -0,0 +1,49 @@
+package loaders
+
+import (
+	"io/ioutil"
+	"testing"
+)
+
+func TestCachingLoader(t *testing.T) {
+	base := NewMemLoader(map[string]string{
+		"/path/to/index.js":   "This is my index file.",
+		"/path/to/lib/a.ts":   "This is my library.",
+		"/path/to/lib/b.d.ts": "These are my types.",
+	})
+	cache := NewCachingLoader(base)
+
+	for i := 0; i < 3; i++ {
+		if actual := cache.Resolve("/path/to/lib/a"); actual != "/path/to/lib/a.ts" {
+			t.Fatalf("Expected %q, got %q", "/path/to/lib/a.ts", actual)
+		}
+		entries, err := cache.ReadDir("/path/to/lib")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(entries) != 2 {
+			t.Fatalf("Expected 2 directory entries, got %d", len(entries))
+		}
+	}
+	if actual := cache.Resolve("/path/to/unknown"); actual != "" {
+		t.Fatalf("Expected an empty string, got %q", actual)
+	}
+
+	expected := CacheStats{ResolveHits: 2, ResolveMisses: 2, ReadDirHits: 2, ReadDirMisses: 1}
+	if actual := cache.Stats(); actual != expected {
+		t.Fatalf("Expected %+v, got %+v", expected, actual)
+	}
+
+	rc, err := cache.Load("/path/to/index.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	content, err := ioutil.ReadAll(rc)
+	rc.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "This is my index file." {
+		t.Fatalf("Expected %q, got %q", "This is my index file.", string(content))
+	}
+}
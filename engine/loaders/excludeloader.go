@@ -0,0 +1,158 @@
+package loaders
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// ExcludeLoader decorates another loader by excluding files that match a set of glob patterns and, optionally,
+// the patterns of any .gitignore files between the root directory and the file. Both use .gitignore syntax.
+// Excluded files still resolve, so that imports of them aren't reported as unresolved, but they can't be loaded
+// and are left out of directory listings. Use Excluded to check whether a file is excluded.
+type ExcludeLoader struct {
+	base       Loader
+	root       string
+	patterns   []ignorePattern
+	gitignore  bool
+	mu         sync.Mutex
+	gitignores map[string][]ignorePattern // Directory -> patterns of its .gitignore file.
+}
+
+// NewExcludeLoader returns a new SourceLoader that excludes the files under root that match any of the given
+// glob patterns, ie. "dist/" or "**/*.generated.ts". If gitignore is true, the patterns of .gitignore files
+// are honoured as well. Symlinks in root are resolved if it exists on disk, since FileLoader resolves them too.
+func NewExcludeLoader(base Loader, root string, globs []string, gitignore bool) *ExcludeLoader {
+	if realRoot, err := filepath.EvalSymlinks(root); err == nil {
+		root = realRoot
+	}
+	exload := ExcludeLoader{
+		base:       base,
+		root:       filepath.Clean(root),
+		patterns:   make([]ignorePattern, 0, len(globs)),
+		gitignore:  gitignore,
+		gitignores: make(map[string][]ignorePattern),
+	}
+	for _, glob := range globs {
+		if pat, ok := parseIgnorePattern(glob); ok {
+			exload.patterns = append(exload.patterns, pat)
+		}
+	}
+	return &exload
+}
+
+// Resolve resolves fname using the underlying loader.
+func (exload *ExcludeLoader) Resolve(fname string) string {
+	return exload.base.Resolve(fname)
+}
+
+// Load returns a reader that you can use to read from the file contents.
+// Returns an error if the file is excluded.
+func (exload *ExcludeLoader) Load(fname string) (io.ReadCloser, error) {
+	return exload.LoadContext(context.Background(), fname)
+}
+
+// LoadContext is like Load, but passes ctx on to the underlying loader.
+func (exload *ExcludeLoader) LoadContext(ctx context.Context, fname string) (io.ReadCloser, error) {
+	if exload.Excluded(fname) {
+		return nil, fmt.Errorf("File is excluded: %q", fname)
+	}
+	return loadContext(ctx, exload.base, fname)
+}
+
+// ReadDir returns the entries of the given directory that aren't excluded.
+// Returns an error if the underlying loader is unable to list directories.
+func (exload *ExcludeLoader) ReadDir(dir string) ([]fs.DirEntry, error) {
+	dr, ok := exload.base.(dirReader)
+	if !ok {
+		return nil, fmt.Errorf("unable to list directory %q: not supported by loader", dir)
+	}
+	entries, err := dr.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := make([]fs.DirEntry, 0, len(entries))
+	for _, entry := range entries {
+		if !exload.excluded(filepath.Join(dir, entry.Name()), entry.IsDir()) {
+			kept = append(kept, entry)
+		}
+	}
+	return kept, nil
+}
+
+// Excluded returns true if the file fname, or any of its parent directories, is excluded.
+// Files outside of the root directory are never excluded.
+func (exload *ExcludeLoader) Excluded(fname string) bool {
+	return exload.excluded(fname, false)
+}
+
+// excluded returns true if fname, or any of its parent directories, is excluded.
+func (exload *ExcludeLoader) excluded(fname string, isDir bool) bool {
+	rel, err := filepath.Rel(exload.root, filepath.Clean(fname))
+	if err != nil || rel == "." || strings.HasPrefix(rel, "..") {
+		return false
+	}
+	segs := strings.Split(filepath.ToSlash(rel), "/")
+
+	// Just like git, a file is excluded if any of its parent directories is.
+	for i := range segs {
+		if exload.matches(segs[:i+1], isDir || i < len(segs)-1) {
+			return true
+		}
+	}
+	return false
+}
+
+// matches returns true if the path made up of segs, relative to the root directory, matches the patterns.
+// Patterns from deeper .gitignore files take precedence, and within a file, the last matching pattern wins.
+func (exload *ExcludeLoader) matches(segs []string, isDir bool) bool {
+	rel := strings.Join(segs, "/")
+	for _, pat := range exload.patterns {
+		if pat.matches(rel, isDir) && !pat.negate {
+			return true
+		}
+	}
+	if !exload.gitignore {
+		return false
+	}
+
+	var ignored bool
+	for i := 0; i < len(segs); i++ {
+		dir := filepath.Join(exload.root, filepath.FromSlash(path.Join(segs[:i]...)))
+		for _, pat := range exload.gitignoreOf(dir) {
+			if pat.matches(strings.Join(segs[i:], "/"), isDir) {
+				ignored = !pat.negate
+			}
+		}
+	}
+	return ignored
+}
+
+// gitignoreOf returns the patterns of the .gitignore file in dir, if any.
+func (exload *ExcludeLoader) gitignoreOf(dir string) []ignorePattern {
+	exload.mu.Lock()
+	pats, ok := exload.gitignores[dir]
+	exload.mu.Unlock()
+	if ok {
+		return pats
+	}
+
+	fname := filepath.Join(dir, ".gitignore")
+	if exload.base.Resolve(fname) != "" {
+		if rc, err := exload.base.Load(fname); err == nil {
+			pats = parseIgnoreFile(rc)
+			rc.Close()
+		}
+	}
+
+	exload.mu.Lock()
+	exload.gitignores[dir] = pats
+	exload.mu.Unlock()
+	return pats
+}
@@ -0,0 +1,111 @@
+package loaders
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestExcludeLoader(t *testing.T) {
+	base := NewMemLoader(map[string]string{
+		"/project/.gitignore":               "build/\n*.log\n!keep.log\n# Comment\n/coverage\n",
+		"/project/index.js":                 "This is my index file.",
+		"/project/build/index.js":           "This is my build output.",
+		"/project/coverage/report.js":       "This is my coverage report.",
+		"/project/lib/coverage/index.js":    "This is not a coverage report.",
+		"/project/lib/.gitignore":           "*.tmp.js\n",
+		"/project/lib/file.tmp.js":          "This is my temporary file.",
+		"/project/lib/file.js":              "This is my library.",
+		"/project/lib/schema.generated.ts":  "This is my generated file.",
+		"/project/debug.log":                "This is my log.",
+		"/project/keep.log":                 "This is my important log.",
+		"/project/src/deep/nested/build.js": "This is not a build directory.",
+	})
+
+	cases := map[string]bool{
+		"/project/index.js":                 false,
+		"/project/build/index.js":           true,
+		"/project/coverage/report.js":       true,
+		"/project/lib/coverage/index.js":    false, // Anchored to the root.
+		"/project/lib/file.tmp.js":          true,
+		"/project/lib/file.js":              false,
+		"/project/lib/schema.generated.ts":  true,
+		"/project/debug.log":                true,
+		"/project/keep.log":                 false,
+		"/project/src/deep/nested/build.js": false, // Only directories match "build/".
+		"/elsewhere/build/index.js":         false,
+	}
+	exload := NewExcludeLoader(base, "/project", []string{"**/*.generated.ts"}, true)
+	for in, expected := range cases {
+		if actual := exload.Excluded(in); actual != expected {
+			t.Fatalf("Excluded(%q): expected %t, got %t", in, expected, actual)
+		}
+	}
+
+	// Excluded files still resolve, but cannot be loaded.
+	if actual := exload.Resolve("/project/build/index"); actual != "/project/build/index.js" {
+		t.Fatalf("Expected %q, got %q", "/project/build/index.js", actual)
+	}
+	if _, err := exload.Load("/project/build/index.js"); err == nil {
+		t.Fatal("Expected an error when loading an excluded file")
+	}
+	if _, err := exload.Load("/project/index.js"); err != nil {
+		t.Fatal(err)
+	}
+
+	entries, err := exload.ReadDir("/project")
+	if err != nil {
+		t.Fatal(err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	expected := []string{".gitignore", "index.js", "keep.log", "lib", "src"}
+	if len(names) != len(expected) {
+		t.Fatalf("Expected entries %q, got %q", expected, names)
+	}
+	for i := range names {
+		if names[i] != expected[i] {
+			t.Fatalf("Expected entries %q, got %q", expected, names)
+		}
+	}
+
+	// Without .gitignore files, only the globs apply.
+	exload = NewExcludeLoader(base, "/project", []string{"build/"}, false)
+	if !exload.Excluded("/project/build/index.js") || exload.Excluded("/project/debug.log") {
+		t.Fatal("Expected only the globs to apply")
+	}
+}
+
+func TestExcludeLoaderResolvesSymlinkedRoot(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esclean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.MkdirAll(filepath.Join(dir, "project/gen"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "project/gen/a.js"), []byte("export const a = 1"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(filepath.Join(dir, "project"), filepath.Join(dir, "link")); err != nil {
+		t.Skip("unable to create symlinks:", err)
+	}
+
+	// FileLoader resolves to the real path, so the excludes must apply to it even when the root is the link.
+	exload := NewExcludeLoader(NewFileLoader(), filepath.Join(dir, "link"), []string{"gen/"}, false)
+	res := exload.Resolve(filepath.Join(dir, "link/gen/a"))
+	if res != filepath.Join(dir, "project/gen/a.js") {
+		t.Fatalf("Expected %q, got %q", filepath.Join(dir, "project/gen/a.js"), res)
+	}
+	if !exload.Excluded(res) {
+		t.Fatalf("Expected %q to be excluded", res)
+	}
+}
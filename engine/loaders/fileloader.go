@@ -3,47 +3,36 @@ package loaders
 import (
 	"fmt"
 	"io"
+	"io/fs"
 	"os"
 	"path/filepath"
-	"strings"
 )
 
 // FileLoader serves byte slices from files.
+// It uses the os package directly rather than an fs.FS, since fs.FS only accepts unrooted, slash-separated paths,
+// which can't express paths on other volumes, ie. C:\project\index.js on Windows.
 type FileLoader struct{}
 
-// NewFileLoader returns a new SourceLoader that serves content straight from memory.
+// NewFileLoader returns a new SourceLoader that serves content from the local file system.
+// File names are expected to be absolute paths.
 func NewFileLoader() *FileLoader {
 	return &FileLoader{}
 }
 
 // Resolve takes a relative path and filename and attempts to resolve it by looking for the underlying file.
-// For example, a JavaScript import statement that refers to a directory, will be resolved to that directory's
-// index.js file, if it exists.
+// The resolved path is canonical, ie. symlinks are evaluated, so that a file that is reachable through
+// several paths (for example through symlinked node_modules directories) will always resolve to the same path.
 // Returns an empty string if unable to guess the file name.
 func (fileload *FileLoader) Resolve(fname string) string {
-	var err error
-
-	// If we have a valid file extension, there's no need to guess.
-	ext := filepath.Ext(fname)
-	if fname == "" || ext == ".js" || ext == ".ts" {
-		return fname
-	}
-
-	fname = strings.TrimRight(fname, "/")
-	options := [6]string{
-		fname + ".ts",
-		fname + ".d.ts",
-		fname + ".js",
-		fname + "/index.js",
-		fname + "/index.ts",
-		fname + "/index.d.ts",
+	res := resolve(fname, fileExists)
+	if res == "" {
+		return ""
 	}
-	for _, opt := range options {
-		if _, err = os.Stat(opt); err == nil {
-			return opt
-		}
+	canonical, err := filepath.EvalSymlinks(res)
+	if err != nil {
+		return res
 	}
-	return "" // Unable to guess.
+	return canonical
 }
 
 // Load returns a reader that you can use to read from the file contents.
@@ -54,3 +43,17 @@ func (fileload *FileLoader) Load(fname string) (io.ReadCloser, error) {
 	}
 	return os.Open(fname)
 }
+
+// ReadDir returns the entries of the given directory, sorted by file name.
+func (fileload *FileLoader) ReadDir(dir string) ([]fs.DirEntry, error) {
+	if dir == "" {
+		return nil, fmt.Errorf("No such directory: %q", dir)
+	}
+	return os.ReadDir(dir)
+}
+
+// fileExists returns true if fname refers to a regular file.
+func fileExists(fname string) bool {
+	info, err := os.Stat(fname)
+	return err == nil && !info.IsDir()
+}
@@ -0,0 +1,50 @@
+package loaders
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFileLoaderResolvesSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esclean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.MkdirAll(filepath.Join(dir, "packages/lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "packages/lib/index.js"), []byte("export function hello() {}"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(dir, "node_modules"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(filepath.Join(dir, "packages/lib"), filepath.Join(dir, "node_modules/lib")); err != nil {
+		t.Skip("unable to create symlinks:", err)
+	}
+
+	expected := filepath.Join(dir, "packages/lib/index.js")
+	cases := []string{
+		filepath.Join(dir, "packages/lib"),
+		filepath.Join(dir, "packages/lib/index.js"),
+		filepath.Join(dir, "node_modules/lib"),
+		filepath.Join(dir, "node_modules/lib/index.js"),
+	}
+
+	fil := NewFileLoader()
+	for _, in := range cases {
+		if actual := fil.Resolve(in); actual != expected {
+			t.Fatalf("Resolve(%q): expected %q, got %q", in, expected, actual)
+		}
+	}
+	if actual := fil.Resolve(filepath.Join(dir, "node_modules/lib/unknown.js")); actual != "" {
+		t.Fatalf("Expected an empty string, got %q", actual)
+	}
+}
@@ -0,0 +1,128 @@
+package loaders
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/mkock/esclean/script"
+)
+
+// FSLoader serves file contents from any fs.FS, such as os.DirFS, embed.FS or a zip.Reader.
+// Since fs.FS only accepts unrooted, slash-separated paths, FSLoader maps the paths it is given
+// onto the file system by stripping root from them.
+type FSLoader struct {
+	fsys fs.FS
+	root string
+}
+
+// NewFSLoader returns a new SourceLoader that serves content from fsys.
+// root is the path that fsys is mounted at, ie. NewFSLoader(os.DirFS("/my/project"), "/my/project")
+// will serve "/my/project/index.js" from the file "index.js" in fsys.
+func NewFSLoader(fsys fs.FS, root string) *FSLoader {
+	return &FSLoader{fsys: fsys, root: path.Clean(filepath.ToSlash(root))}
+}
+
+// Resolve takes a relative path and filename and attempts to resolve it by looking for the underlying file.
+// For example, a JavaScript import statement that refers to a directory, will be resolved to that directory's
+// index.js file, if it exists.
+// Returns an empty string if unable to guess the file name.
+func (fsload *FSLoader) Resolve(fname string) string {
+	return resolve(fname, fsload.exists)
+}
+
+// Load returns a reader that you can use to read from the file contents.
+// Returns an error if the file does not exist.
+func (fsload *FSLoader) Load(fname string) (io.ReadCloser, error) {
+	name, ok := fsload.name(fname)
+	if !ok || fname == "" {
+		return nil, fmt.Errorf("No such file: %q", fname)
+	}
+	return fsload.fsys.Open(name)
+}
+
+// ReadDir returns the entries of the given directory, sorted by file name.
+func (fsload *FSLoader) ReadDir(dir string) ([]fs.DirEntry, error) {
+	name, ok := fsload.name(dir)
+	if !ok || dir == "" {
+		return nil, fmt.Errorf("No such directory: %q", dir)
+	}
+	return fs.ReadDir(fsload.fsys, name)
+}
+
+// exists returns true if fname refers to a regular file.
+func (fsload *FSLoader) exists(fname string) bool {
+	name, ok := fsload.name(fname)
+	if !ok {
+		return false
+	}
+	info, err := fs.Stat(fsload.fsys, name)
+	return err == nil && !info.IsDir()
+}
+
+// name maps fname to a path that is valid for use with the underlying fs.FS.
+// Returns false if fname is outside of the loader's root.
+func (fsload *FSLoader) name(fname string) (string, bool) {
+	fname = path.Clean(filepath.ToSlash(fname))
+	switch {
+	case fname == fsload.root:
+		return ".", true
+	case fsload.root == "/":
+		fname = strings.TrimPrefix(fname, "/")
+	case fsload.root != ".":
+		if !strings.HasPrefix(fname, fsload.root+"/") {
+			return "", false
+		}
+		fname = fname[len(fsload.root)+1:]
+	}
+	if !fs.ValidPath(fname) {
+		return "", false
+	}
+	return fname, true
+}
+
+// resolve implements the resolution algorithm shared by all loaders. exists should return true if the given
+// path refers to an existing file.
+func resolve(fname string, exists func(string) bool) string {
+	if fname == "" {
+		return ""
+	}
+
+	// If we have a valid file extension, there's no need to guess.
+	ext := filepath.Ext(fname)
+	if ext != "" && script.IsScript(fname) {
+		if exists(fname) {
+			return fname
+		}
+		return ""
+	}
+
+	// Non-script files, such as JSON, stylesheets and images, are imported by their full name.
+	if ext != "" && exists(fname) {
+		return fname
+	}
+
+	for _, opt := range candidates(fname) {
+		if exists(opt) {
+			return opt
+		}
+	}
+	return "" // Unable to guess.
+}
+
+// candidates returns the file names that fname may refer to, in order of preference: a script with one of the
+// extensions in script.ScriptExts, or an index script of the directory fname.
+func candidates(fname string) []string {
+	fname = strings.TrimRight(fname, "/")
+	opts := make([]string, 0, 2*len(script.ScriptExts))
+	for _, ext := range script.ScriptExts {
+		opts = append(opts, fname+ext)
+	}
+	for _, ext := range script.ScriptExts {
+		opts = append(opts, fname+"/index"+ext)
+	}
+	return opts
+}
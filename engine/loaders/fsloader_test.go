@@ -0,0 +1,70 @@
+package loaders
+
+import (
+	"io/ioutil"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFSLoaderResolve(t *testing.T) {
+	fsys := fstest.MapFS{
+		"index.js":                &fstest.MapFile{Data: []byte("This is my index file.")},
+		"file.ts":                 &fstest.MapFile{Data: []byte("This is my file.")},
+		"lib/index.ts":            &fstest.MapFile{Data: []byte("This is my library.")},
+		"lib/data.json":           &fstest.MapFile{Data: []byte("{}")},
+		"lib/definitionFile.d.ts": &fstest.MapFile{Data: []byte("This is a type definition file.")},
+		"components/Button.tsx":   &fstest.MapFile{Data: []byte("This is my component.")},
+		"components/Icon.jsx":     &fstest.MapFile{Data: []byte("This is my other component.")},
+		"server/index.mjs":        &fstest.MapFile{Data: []byte("This is my server.")},
+		"server/config.cts":       &fstest.MapFile{Data: []byte("This is my config.")},
+	}
+	cases := map[string]string{
+		"/project/":                    "/project/index.js",
+		"/project/file.ts":             "/project/file.ts",
+		"/project/file":                "/project/file.ts",
+		"/project/lib":                 "/project/lib/index.ts",
+		"/project/lib/definitionFile":  "/project/lib/definitionFile.d.ts",
+		"/project/lib/data.json":       "/project/lib/data.json",
+		"/project/components/Button":   "/project/components/Button.tsx",
+		"/project/components/Icon":     "/project/components/Icon.jsx",
+		"/project/components/Icon.jsx": "/project/components/Icon.jsx",
+		"/project/server":              "/project/server/index.mjs",
+		"/project/server/config":       "/project/server/config.cts",
+		"/project/lib/unknown.json":    "", // error.
+		"/project/unknownFile.ts":      "", // error.
+		"/elsewhere/file.ts":           "", // error, outside of root.
+		"":                             "", // error.
+	}
+
+	fsl := NewFSLoader(fsys, "/project")
+	for in, expected := range cases {
+		actual := fsl.Resolve(in)
+		if actual != expected {
+			t.Fatalf("Resolve(%q): expected %q, got %q", in, expected, actual)
+		}
+	}
+}
+
+func TestFSLoaderLoad(t *testing.T) {
+	fsys := fstest.MapFS{
+		"file.ts": &fstest.MapFile{Data: []byte("This is my file.")},
+	}
+
+	fsl := NewFSLoader(fsys, "/project")
+	rc, err := fsl.Load("/project/file.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	actual, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != "This is my file." {
+		t.Fatalf("Expected %q, got %q", "This is my file.", string(actual))
+	}
+
+	if _, err = fsl.Load("/elsewhere/file.ts"); err == nil {
+		t.Fatal("Expected an error for a file outside of root")
+	}
+}
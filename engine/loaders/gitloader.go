@@ -0,0 +1,186 @@
+package loaders
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// GitLoader serves file contents from a given revision of a local git repository, leaving the working tree alone.
+// It shells out to the local git binary, so git must be installed and available in $PATH. File contents are read
+// through a single "git cat-file --batch" process, which is started on the first Load. Remember to call Close
+// when done. GitLoader is safe for concurrent use.
+type GitLoader struct {
+	*FSLoader
+	root, rev string
+	blobs     map[string]string
+	mu        sync.Mutex
+	batch     *catFile
+}
+
+// NewGitLoader returns a new SourceLoader that serves content from the given revision (ie. a branch, tag or commit)
+// of the git repository that contains dir. Files are served using their absolute path in the working tree, so
+// "/my/repo/index.js" will be served from "index.js" at the given revision.
+func NewGitLoader(dir, rev string) (*GitLoader, error) {
+	// Don't let git parse the revision as an option.
+	if strings.HasPrefix(rev, "-") {
+		return nil, fmt.Errorf("invalid revision: %q", rev)
+	}
+	out, err := git(dir, "rev-parse", "--show-toplevel")
+	if err != nil {
+		return nil, err
+	}
+	root := strings.TrimSpace(string(out))
+
+	// List all blobs in the revision. Output lines look like "<mode> <type> <object>\t<path>".
+	if out, err = git(root, "ls-tree", "-r", "-z", "--full-tree", rev); err != nil {
+		return nil, err
+	}
+	fsys := make(mapFS, 100)
+	blobs := make(map[string]string, 100)
+	for _, line := range strings.Split(string(out), "\x00") {
+		tab := strings.IndexByte(line, '\t')
+		if tab < 0 {
+			continue
+		}
+		meta := strings.Fields(line[:tab])
+		if len(meta) != 3 || meta[1] != "blob" {
+			continue
+		}
+		fname := line[tab+1:]
+		fsys[fname] = nil
+		blobs[path.Join(root, fname)] = meta[2]
+	}
+
+	return &GitLoader{FSLoader: NewFSLoader(fsys, root), root: root, rev: rev, blobs: blobs}, nil
+}
+
+// Root returns the absolute path to the top level directory of the git repository.
+func (gitload *GitLoader) Root() string {
+	return gitload.root
+}
+
+// Load returns a reader that you can use to read from the file contents at the loader's revision.
+// Returns an error if the file does not exist in that revision.
+func (gitload *GitLoader) Load(fname string) (io.ReadCloser, error) {
+	return gitload.LoadContext(context.Background(), fname)
+}
+
+// LoadContext is like Load, but fails if ctx is cancelled before the file is read. Blobs are read one at a time,
+// so a read that has started is completed.
+func (gitload *GitLoader) LoadContext(ctx context.Context, fname string) (io.ReadCloser, error) {
+	blob, ok := gitload.blobs[path.Clean(filepath.ToSlash(fname))]
+	if !ok {
+		return nil, fmt.Errorf("No such file: %q at revision %q", fname, gitload.rev)
+	}
+
+	gitload.mu.Lock()
+	defer gitload.mu.Unlock()
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	if gitload.batch == nil {
+		batch, err := startCatFile(gitload.root)
+		if err != nil {
+			return nil, err
+		}
+		gitload.batch = batch
+	}
+	out, err := gitload.batch.read(blob)
+	if err != nil {
+		// The output of git can't be trusted after an error, so a new process is started next time.
+		gitload.batch.close()
+		gitload.batch = nil
+		return nil, err
+	}
+	return ioutil.NopCloser(bytes.NewReader(out)), nil
+}
+
+// Close stops the git process that serves file contents, if it is running.
+func (gitload *GitLoader) Close() error {
+	gitload.mu.Lock()
+	defer gitload.mu.Unlock()
+	if gitload.batch == nil {
+		return nil
+	}
+	err := gitload.batch.close()
+	gitload.batch = nil
+	return err
+}
+
+// catFile is a running "git cat-file --batch" process, which reads object names from stdin and writes
+// the objects to stdout.
+type catFile struct {
+	cmd    *exec.Cmd
+	stdin  io.WriteCloser
+	stdout *bufio.Reader
+}
+
+// startCatFile starts "git cat-file --batch" inside dir.
+func startCatFile(dir string) (*catFile, error) {
+	cmd := exec.Command("git", "-C", dir, "cat-file", "--batch")
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, err
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, err
+	}
+	if err = cmd.Start(); err != nil {
+		return nil, fmt.Errorf("git cat-file --batch: %s", err)
+	}
+	return &catFile{cmd: cmd, stdin: stdin, stdout: bufio.NewReader(stdout)}, nil
+}
+
+// read returns the contents of the object with the given name. git replies with a "<object> <type> <size>"
+// header followed by the contents and a newline, or with "<object> missing".
+func (cf *catFile) read(object string) ([]byte, error) {
+	if _, err := fmt.Fprintf(cf.stdin, "%s\n", object); err != nil {
+		return nil, fmt.Errorf("git cat-file --batch: %s", err)
+	}
+	header, err := cf.stdout.ReadString('\n')
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file --batch: %s", err)
+	}
+	fields := strings.Fields(header)
+	if len(fields) != 3 {
+		return nil, fmt.Errorf("git cat-file --batch: unable to read object %s: %q", object, strings.TrimSpace(header))
+	}
+	size, err := strconv.Atoi(fields[2])
+	if err != nil {
+		return nil, fmt.Errorf("git cat-file --batch: invalid size in %q", strings.TrimSpace(header))
+	}
+	content := make([]byte, size+1)
+	if _, err = io.ReadFull(cf.stdout, content); err != nil {
+		return nil, fmt.Errorf("git cat-file --batch: %s", err)
+	}
+	return content[:size], nil
+}
+
+// close stops the process by closing its input.
+func (cf *catFile) close() error {
+	cf.stdin.Close()
+	return cf.cmd.Wait()
+}
+
+// git runs the git binary with the given arguments inside dir and returns its output.
+func git(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", append([]string{"-C", dir}, args...)...)
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("git %s: %s: %s", strings.Join(args, " "), err, strings.TrimSpace(stderr.String()))
+	}
+	return out, nil
+}
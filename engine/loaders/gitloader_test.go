@@ -0,0 +1,112 @@
+package loaders
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestGitLoader(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir, err := ioutil.TempDir("", "esclean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(args ...string) {
+		if _, err := git(dir, args...); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write := func(name, content string) {
+		if err := os.MkdirAll(filepath.Dir(filepath.Join(dir, name)), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q")
+	write("index.js", "import { hello } from './lib'")
+	write("lib/index.js", "export function hello() {}")
+	run("add", "-A")
+	run("-c", "user.name=esclean", "-c", "user.email=esclean@example.com", "commit", "-q", "-m", "Initial commit")
+	run("tag", "v1")
+
+	// Change the working tree after tagging; the loader must not see these changes.
+	write("index.js", "import { goodbye } from './lib'")
+	write("lib/other.js", "export function goodbye() {}")
+
+	gitl, err := NewGitLoader(filepath.Join(dir, "lib"), "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer gitl.Close()
+	if gitl.Root() != dir {
+		t.Fatalf("Expected root %q, got %q", dir, gitl.Root())
+	}
+
+	cases := map[string]string{
+		filepath.Join(dir, "lib"):          filepath.Join(dir, "lib/index.js"),
+		filepath.Join(dir, "index.js"):     filepath.Join(dir, "index.js"),
+		filepath.Join(dir, "lib/other.js"): "", // not part of the revision.
+	}
+	for in, expected := range cases {
+		if actual := gitl.Resolve(in); actual != expected {
+			t.Fatalf("Resolve(%q): expected %q, got %q", in, expected, actual)
+		}
+	}
+
+	rc, err := gitl.Load(filepath.Join(dir, "index.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	actual, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != "import { hello } from './lib'" {
+		t.Fatalf("Expected committed content, got %q", string(actual))
+	}
+
+	// Files are read through the same git process, which is restarted after Close.
+	for _, fname := range []string{"lib/index.js", "index.js"} {
+		rc, err := gitl.Load(filepath.Join(dir, fname))
+		if err != nil {
+			t.Fatal(err)
+		}
+		rc.Close()
+		if err = gitl.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// Loading with a cancelled context should fail, and through a CachingLoader as well.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err = gitl.LoadContext(ctx, filepath.Join(dir, "index.js")); err != context.Canceled {
+		t.Fatalf("Expected %v, got %v", context.Canceled, err)
+	}
+	if _, err = NewCachingLoader(gitl).LoadContext(ctx, filepath.Join(dir, "index.js")); err != context.Canceled {
+		t.Fatalf("Expected %v, got %v", context.Canceled, err)
+	}
+
+	if _, err = NewGitLoader(dir, "no-such-revision"); err == nil {
+		t.Fatal("Expected an error for an unknown revision")
+	}
+	if _, err = NewGitLoader(dir, "--output=/dev/null"); err == nil {
+		t.Fatal("Expected an error for a revision that looks like an option")
+	}
+}
@@ -0,0 +1,89 @@
+package loaders
+
+import (
+	"bufio"
+	"io"
+	"path"
+	"strings"
+)
+
+// An ignorePattern is a single pattern in .gitignore syntax.
+type ignorePattern struct {
+	glob            string
+	negate, dirOnly bool
+	anchored        bool // True if the pattern must match the full path, not just the base name.
+}
+
+// parseIgnorePattern parses a single line of a .gitignore file. Returns false for blank lines and comments.
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	var pat ignorePattern
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pat, false
+	}
+	if strings.HasPrefix(line, "!") {
+		pat.negate = true
+		line = line[1:]
+	}
+	line = strings.TrimPrefix(line, "\\")
+	if strings.HasSuffix(line, "/") {
+		pat.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+	if strings.Contains(line, "/") {
+		pat.anchored = true
+		line = strings.TrimPrefix(line, "/")
+	}
+	pat.glob = line
+
+	return pat, line != ""
+}
+
+// parseIgnoreFile parses the contents of a .gitignore file.
+func parseIgnoreFile(r io.Reader) []ignorePattern {
+	pats := make([]ignorePattern, 0, 10)
+	sc := bufio.NewScanner(r)
+	for sc.Scan() {
+		if pat, ok := parseIgnorePattern(sc.Text()); ok {
+			pats = append(pats, pat)
+		}
+	}
+	return pats
+}
+
+// matches returns true if the pattern matches rel, which is a slash-separated path relative to the directory
+// that the pattern applies to.
+func (pat ignorePattern) matches(rel string, isDir bool) bool {
+	if pat.dirOnly && !isDir {
+		return false
+	}
+	if !pat.anchored {
+		ok, _ := path.Match(pat.glob, path.Base(rel))
+		return ok
+	}
+	return matchGlob(strings.Split(pat.glob, "/"), strings.Split(rel, "/"))
+}
+
+// matchGlob matches the path segments segs against the pattern segments pats, where "**" matches
+// any number of segments.
+func matchGlob(pats, segs []string) bool {
+	for len(pats) > 0 {
+		if pats[0] == "**" {
+			for i := 0; i <= len(segs); i++ {
+				if matchGlob(pats[1:], segs[i:]) {
+					return true
+				}
+			}
+			return false
+		}
+		if len(segs) == 0 {
+			return false
+		}
+		if ok, _ := path.Match(pats[0], segs[0]); !ok {
+			return false
+		}
+		pats, segs = pats[1:], segs[1:]
+	}
+	return len(segs) == 0
+}
@@ -0,0 +1,41 @@
+package loaders
+
+import (
+	"context"
+	"io"
+	"io/fs"
+)
+
+// A Loader is a source of file contents; it matches engine.SourceLoader.
+// It is declared here so that the loaders in this package can decorate other loaders.
+type Loader interface {
+	Resolve(fname string) string
+	Load(fname string) (io.ReadCloser, error)
+}
+
+// A dirReader is a Loader that is also able to list the contents of a directory; it matches engine.DirReader.
+type dirReader interface {
+	ReadDir(dir string) ([]fs.DirEntry, error)
+}
+
+// A contextLoader is a Loader that is able to stop loading a file when a context is cancelled; it matches
+// engine.ContextLoader.
+type contextLoader interface {
+	LoadContext(ctx context.Context, fname string) (io.ReadCloser, error)
+}
+
+// loadContext loads fname from base, using LoadContext if base supports it.
+func loadContext(ctx context.Context, base Loader, fname string) (io.ReadCloser, error) {
+	if cl, ok := base.(contextLoader); ok {
+		return cl.LoadContext(ctx, fname)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return base.Load(fname)
+}
+
+// An excluder is a Loader that excludes some of the files that it is able to resolve; it matches engine.Excluder.
+type excluder interface {
+	Excluded(fname string) bool
+}
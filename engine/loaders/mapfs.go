@@ -0,0 +1,144 @@
+package loaders
+
+import (
+	"bytes"
+	"io"
+	"io/fs"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// mapFS is a read-only fs.FS that serves file contents from a map of slash-separated, unrooted paths.
+// Directories are not stored, but implied by the paths of the files inside them.
+type mapFS map[string][]byte
+
+// Open opens the named file or directory.
+func (fsys mapFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+	if data, ok := fsys[name]; ok {
+		return &mapFile{info: mapInfo{name: path.Base(name), size: int64(len(data))}, r: bytes.NewReader(data)}, nil
+	}
+	entries, ok := fsys.entries(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrNotExist}
+	}
+	return &mapDir{info: mapInfo{name: path.Base(name), dir: true}, entries: entries}, nil
+}
+
+// Stat returns information about the named file or directory.
+func (fsys mapFS) Stat(name string) (fs.FileInfo, error) {
+	f, err := fsys.Open(name)
+	if err != nil {
+		return nil, &fs.PathError{Op: "stat", Path: name, Err: err.(*fs.PathError).Err}
+	}
+	return f.Stat()
+}
+
+// ReadDir returns the entries of the named directory, sorted by file name.
+func (fsys mapFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if !fs.ValidPath(name) {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrInvalid}
+	}
+	entries, ok := fsys.entries(name)
+	if !ok {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+	return entries, nil
+}
+
+// entries returns the sorted entries of the directory dir.
+// Returns false if no file exists inside dir.
+func (fsys mapFS) entries(dir string) ([]fs.DirEntry, bool) {
+	prefix := dir + "/"
+	if dir == "." {
+		prefix = ""
+	}
+	seen := make(map[string]bool)
+	var entries []fs.DirEntry
+	for fname, data := range fsys {
+		if !strings.HasPrefix(fname, prefix) {
+			continue
+		}
+		rest := fname[len(prefix):]
+		if i := strings.IndexByte(rest, '/'); i >= 0 {
+			if !seen[rest[:i]] {
+				seen[rest[:i]] = true
+				entries = append(entries, mapInfo{name: rest[:i], dir: true})
+			}
+			continue
+		}
+		if !seen[rest] {
+			seen[rest] = true
+			entries = append(entries, mapInfo{name: rest, size: int64(len(data))})
+		}
+	}
+	if len(entries) == 0 && dir != "." {
+		return nil, false
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return entries, true
+}
+
+// mapInfo describes a file or directory in a mapFS. It implements both fs.FileInfo and fs.DirEntry.
+type mapInfo struct {
+	name string
+	size int64
+	dir  bool
+}
+
+func (info mapInfo) Name() string               { return info.name }
+func (info mapInfo) Size() int64                { return info.size }
+func (info mapInfo) ModTime() time.Time         { return time.Time{} }
+func (info mapInfo) IsDir() bool                { return info.dir }
+func (info mapInfo) Sys() interface{}           { return nil }
+func (info mapInfo) Type() fs.FileMode          { return info.Mode().Type() }
+func (info mapInfo) Info() (fs.FileInfo, error) { return info, nil }
+
+func (info mapInfo) Mode() fs.FileMode {
+	if info.dir {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
+
+// mapFile is an open file in a mapFS.
+type mapFile struct {
+	info mapInfo
+	r    *bytes.Reader
+}
+
+func (f *mapFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *mapFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+func (f *mapFile) Close() error               { return nil }
+
+// mapDir is an open directory in a mapFS.
+type mapDir struct {
+	info    mapInfo
+	entries []fs.DirEntry
+}
+
+func (d *mapDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *mapDir) Close() error               { return nil }
+
+func (d *mapDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: fs.ErrInvalid}
+}
+
+// ReadDir returns the next n entries of the directory, or all remaining entries if n <= 0.
+func (d *mapDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	if n <= 0 || n >= len(d.entries) {
+		if n > 0 && len(d.entries) == 0 {
+			return nil, io.EOF
+		}
+		entries := d.entries
+		d.entries = nil
+		return entries, nil
+	}
+	entries := d.entries[:n]
+	d.entries = d.entries[n:]
+	return entries, nil
+}
@@ -0,0 +1,18 @@
+package loaders
+
+import (
+	"testing"
+	"testing/fstest"
+)
+
+func TestMapFS(t *testing.T) {
+	fsys := mapFS{
+		"index.js":          []byte("import './lib/util'"),
+		"lib/util.js":       []byte("export const a = 1"),
+		"lib/deep/more.js":  []byte(""),
+		"styles/theme.scss": []byte("$a: 1;"),
+	}
+	if err := fstest.TestFS(fsys, "index.js", "lib/util.js", "lib/deep/more.js", "styles/theme.scss"); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,112 @@
+package loaders
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"path/filepath"
+	"sort"
+)
+
+// OverlayLoader layers a set of in-memory files, ie. unsaved editor buffers, on top of another loader.
+// Files in the overlay take precedence over files from the underlying loader.
+type OverlayLoader struct {
+	base    Loader
+	overlay *MemLoader
+}
+
+// NewOverlayLoader returns a new SourceLoader that serves content from overlay, falling back to base
+// for any file that isn't part of the overlay. Like FileLoader, the overlay canonicalises paths, so a file
+// matches the overlay whether it is reached through a symlinked directory or not.
+func NewOverlayLoader(base Loader, overlay map[string]string) *OverlayLoader {
+	files := make(map[string]string, len(overlay))
+	for fname, content := range overlay {
+		files[canonicalPath(fname)] = content
+	}
+	return &OverlayLoader{base: base, overlay: NewMemLoader(files)}
+}
+
+// canonicalPath returns fname with any symlinks in its directory evaluated. The file itself may not exist on
+// disk, ie. if it is a new, unsaved file. Returns fname if its directory doesn't exist on disk either.
+func canonicalPath(fname string) string {
+	dir, err := filepath.EvalSymlinks(filepath.Dir(fname))
+	if err != nil {
+		return fname
+	}
+	return filepath.Join(dir, filepath.Base(fname))
+}
+
+// inOverlay returns the canonical path of fname, and true if that is an overlay file.
+func (ovload *OverlayLoader) inOverlay(fname string) (string, bool) {
+	fname = canonicalPath(fname)
+	return fname, ovload.overlay.exists(fname)
+}
+
+// Resolve takes a relative path and filename and attempts to resolve it by looking for the underlying file
+// in both the overlay and the underlying loader. The usual order of preference is kept, so an overlay file
+// will not shadow a better match from the underlying loader.
+// Returns an empty string if unable to guess the file name.
+func (ovload *OverlayLoader) Resolve(fname string) string {
+	// The underlying loader may canonicalise the path, so we keep its result rather than the candidate.
+	var res string
+	resolve(fname, func(opt string) bool {
+		if canonical, ok := ovload.inOverlay(opt); ok {
+			res = canonical
+			return true
+		}
+		res = ovload.base.Resolve(opt)
+		return res != ""
+	})
+	return res
+}
+
+// Load returns a reader that you can use to read from the file contents.
+// Returns an error if the file exists in neither the overlay nor the underlying loader.
+func (ovload *OverlayLoader) Load(fname string) (io.ReadCloser, error) {
+	return ovload.LoadContext(context.Background(), fname)
+}
+
+// LoadContext is like Load, but passes ctx on to the underlying loader.
+func (ovload *OverlayLoader) LoadContext(ctx context.Context, fname string) (io.ReadCloser, error) {
+	if canonical, ok := ovload.inOverlay(fname); ok {
+		return ovload.overlay.Load(canonical)
+	}
+	return loadContext(ctx, ovload.base, fname)
+}
+
+// ReadDir returns the entries of the given directory from the underlying loader, merged with the overlay
+// files in that directory. Returns an error if the directory exists in neither.
+func (ovload *OverlayLoader) ReadDir(dir string) ([]fs.DirEntry, error) {
+	entries, ovErr := ovload.overlay.ReadDir(dir)
+
+	baseErr := fmt.Errorf("unable to list directory %q: not supported by loader", dir)
+	if dr, ok := ovload.base.(dirReader); ok {
+		var baseEntries []fs.DirEntry
+		if baseEntries, baseErr = dr.ReadDir(dir); baseErr == nil {
+			seen := make(map[string]bool, len(entries))
+			for _, entry := range entries {
+				seen[entry.Name()] = true
+			}
+			for _, entry := range baseEntries {
+				if !seen[entry.Name()] {
+					entries = append(entries, entry)
+				}
+			}
+			sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+		}
+	}
+	if ovErr != nil && baseErr != nil {
+		return nil, baseErr
+	}
+	return entries, nil
+}
+
+// Excluded returns true if the underlying loader excludes fname. Overlay files are never excluded.
+func (ovload *OverlayLoader) Excluded(fname string) bool {
+	if _, ok := ovload.inOverlay(fname); ok {
+		return false
+	}
+	ex, ok := ovload.base.(excluder)
+	return ok && ex.Excluded(fname)
+}
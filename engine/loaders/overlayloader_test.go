@@ -0,0 +1,114 @@
+package loaders
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestOverlayLoader(t *testing.T) {
+	base := NewMemLoader(map[string]string{
+		"/path/to/index.js": "This is my index file.",
+		"/path/to/file.ts":  "This is my file.",
+		"/path/to/other.js": "This is my other file.",
+	})
+	overlay := map[string]string{
+		"/path/to/index.js": "This is my unsaved index file.",
+		"/path/to/file.js":  "This is my new file.",
+		"/path/to/new.js":   "This is my new file.",
+	}
+	ovl := NewOverlayLoader(base, overlay)
+
+	resolveCases := map[string]string{
+		"/path/to/":        "/path/to/index.js",
+		"/path/to/file":    "/path/to/file.ts", // .ts is preferred to .js.
+		"/path/to/file.js": "/path/to/file.js",
+		"/path/to/new":     "/path/to/new.js",
+		"/path/to/other":   "/path/to/other.js",
+		"/path/to/unknown": "",
+	}
+	for in, expected := range resolveCases {
+		if actual := ovl.Resolve(in); actual != expected {
+			t.Fatalf("Resolve(%q): expected %q, got %q", in, expected, actual)
+		}
+	}
+
+	loadCases := map[string]string{
+		"/path/to/index.js": "This is my unsaved index file.",
+		"/path/to/file.ts":  "This is my file.",
+		"/path/to/new.js":   "This is my new file.",
+	}
+	for in, expected := range loadCases {
+		rc, err := ovl.Load(in)
+		if err != nil {
+			t.Fatal(err)
+		}
+		actual, err := ioutil.ReadAll(rc)
+		rc.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(actual) != expected {
+			t.Fatalf("Load(%q): expected %q, got %q", in, expected, string(actual))
+		}
+	}
+
+	if _, err := ovl.Load("/path/to/unknown.js"); err == nil {
+		t.Fatal("Expected an error for an unknown file")
+	}
+}
+
+func TestOverlayLoaderResolvesSymlinks(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esclean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	if dir, err = filepath.EvalSymlinks(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if err = os.MkdirAll(filepath.Join(dir, "packages/lib"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = ioutil.WriteFile(filepath.Join(dir, "packages/lib/index.js"), []byte("This is my index file."), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.MkdirAll(filepath.Join(dir, "node_modules"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err = os.Symlink(filepath.Join(dir, "packages/lib"), filepath.Join(dir, "node_modules/lib")); err != nil {
+		t.Skip("unable to create symlinks:", err)
+	}
+
+	// The editor knows the files by the path that was opened, which goes through the symlink.
+	ovl := NewOverlayLoader(NewFileLoader(), map[string]string{
+		filepath.Join(dir, "node_modules/lib/index.js"): "This is my unsaved index file.",
+		filepath.Join(dir, "node_modules/lib/new.js"):   "This is my new file.",
+	})
+
+	resolveCases := map[string]string{
+		filepath.Join(dir, "packages/lib"):     filepath.Join(dir, "packages/lib/index.js"),
+		filepath.Join(dir, "node_modules/lib"): filepath.Join(dir, "packages/lib/index.js"),
+		filepath.Join(dir, "packages/lib/new"): filepath.Join(dir, "packages/lib/new.js"),
+	}
+	for in, expected := range resolveCases {
+		if actual := ovl.Resolve(in); actual != expected {
+			t.Fatalf("Resolve(%q): expected %q, got %q", in, expected, actual)
+		}
+	}
+
+	rc, err := ovl.Load(filepath.Join(dir, "packages/lib/index.js"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer rc.Close()
+	actual, err := ioutil.ReadAll(rc)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(actual) != "This is my unsaved index file." {
+		t.Fatalf("Expected the overlay contents, got %q", string(actual))
+	}
+}
@@ -0,0 +1,107 @@
+package engine
+
+import (
+	"fmt"
+)
+
+// An Option configures an Engine.
+type Option func(*Engine)
+
+// An UnresolvedPolicy determines how an Engine handles import statements that cannot be resolved to a file.
+type UnresolvedPolicy uint8
+
+// Policies for unresolved imports.
+const (
+	// UnresolvedFail aborts the analysis on the first unresolved import. This is the default.
+	UnresolvedFail UnresolvedPolicy = iota
+	// UnresolvedWarn lists unresolved imports in the Report and continues the analysis.
+	UnresolvedWarn
+	// UnresolvedIgnore skips unresolved imports silently.
+	UnresolvedIgnore
+)
+
+// ParseUnresolvedPolicy returns the UnresolvedPolicy with the given name; one of "fail", "warn" or "ignore".
+func ParseUnresolvedPolicy(name string) (UnresolvedPolicy, error) {
+	switch name {
+	case "fail":
+		return UnresolvedFail, nil
+	case "warn":
+		return UnresolvedWarn, nil
+	case "ignore":
+		return UnresolvedIgnore, nil
+	}
+	return UnresolvedFail, fmt.Errorf("unknown policy for unresolved imports: %q", name)
+}
+
+// WithUnresolvedPolicy sets the policy for handling import statements that cannot be resolved to a file.
+func WithUnresolvedPolicy(policy UnresolvedPolicy) Option {
+	return func(ng *Engine) {
+		ng.unresolvedPolicy = policy
+	}
+}
+
+// WithEntries adds more entry files to the analysis, ie. service workers, web workers or CLI scripts.
+// All entry files are traversed together, so exports that are used by any of them are considered used.
+func WithEntries(entries ...string) Option {
+	return func(ng *Engine) {
+		ng.entries = append(ng.entries, entries...)
+	}
+}
+
+// WithOrphans makes the Engine report the source files in dir, and its subdirectories, that aren't reached
+// from any of the entry files. The loader must be a DirReader.
+func WithOrphans(dir string) Option {
+	return func(ng *Engine) {
+		ng.orphanDir = dir
+	}
+}
+
+// WithTransitive makes the Engine report the exports that are only used by code that is unreachable from the
+// entry files, such as exports that are only used by unused exports. Their RefCounts hide that they are dead.
+func WithTransitive() Option {
+	return func(ng *Engine) {
+		ng.transitive = true
+	}
+}
+
+// WithWorkers sets the number of files that are loaded and parsed concurrently. It defaults to the number of CPUs.
+// The Report is the same regardless of the number of workers.
+func WithWorkers(n int) Option {
+	return func(ng *Engine) {
+		ng.workers = n
+	}
+}
+
+// WithCacheDir makes the Engine keep the results of parsing scripts in dir, and reuse them on the next run for
+// the files whose contents haven't changed. The directory is created if it doesn't exist.
+func WithCacheDir(dir string) Option {
+	return func(ng *Engine) {
+		ng.cache = &parseCache{dir: dir}
+	}
+}
+
+// WithResolver adds a SpecifierResolver that maps bare import specifiers to project files.
+// Resolvers are consulted in the order that they are added. Bare imports that no resolver maps to a file
+// are considered to be external and are ignored.
+func WithResolver(resolver SpecifierResolver) Option {
+	return func(ng *Engine) {
+		ng.resolvers = append(ng.resolvers, resolver)
+	}
+}
+
+// WithPackages makes the Engine follow imports of the packages with the given names into node_modules, and
+// report the usage of their exports separately. This is useful for local libraries that are linked there.
+func WithPackages(names ...string) Option {
+	return func(ng *Engine) {
+		ng.packages = NewPackageResolver(ng.loader, names...)
+		ng.resolvers = append(ng.resolvers, ng.packages)
+	}
+}
+
+// WithProgress makes the Engine report its progress to fn, ie. to show a progress bar. fn is called from a
+// single goroutine, one event at a time, so it should return quickly.
+func WithProgress(fn func(Event)) Option {
+	return func(ng *Engine) {
+		ng.progress.fn = fn
+	}
+}
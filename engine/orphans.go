@@ -0,0 +1,108 @@
+package engine
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mkock/esclean/script"
+)
+
+// An Orphan is a source file that isn't reached from any of the entry files.
+type Orphan struct {
+	File  string // Relative to the directory that orphans are looked for in.
+	Lines int
+}
+
+// findOrphans walks the directory ng.orphanDir and returns the source files that weren't visited, sorted by
+// file name, along with their total line count. node_modules and hidden directories are skipped, and so are
+// declaration files, since they are often picked up by the compiler without being imported.
+func (ng *Engine) findOrphans() ([]Orphan, int, error) {
+	dr, ok := ng.loader.(DirReader)
+	if !ok {
+		return nil, 0, fmt.Errorf("unable to find orphan files: the loader cannot list directories")
+	}
+
+	fnames, err := sourcesIn(dr, ng.orphanDir)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	orphans := make([]Orphan, 0, 10)
+	var total int
+	for _, walked := range fnames {
+		// The loader may canonicalise the path, ie. if the directory is reached through a symlink.
+		fname := walked
+		if res := ng.loader.Resolve(fname); res != "" {
+			fname = res
+		}
+		if _, ok := ng.tree[fname]; ok {
+			continue
+		}
+		lines, err := ng.countLines(fname)
+		if err != nil {
+			return nil, 0, err
+		}
+		// The entry files may be in a subdirectory, so the paths are relative to the walked directory instead.
+		rel, err := filepath.Rel(ng.orphanDir, walked)
+		if err != nil {
+			return nil, 0, err
+		}
+		orphans = append(orphans, Orphan{File: "./" + filepath.ToSlash(rel), Lines: lines})
+		total += lines
+	}
+	sort.Slice(orphans, func(i, j int) bool { return orphans[i].File < orphans[j].File })
+
+	return orphans, total, nil
+}
+
+// countLines returns the number of lines in the file fname.
+func (ng *Engine) countLines(fname string) (int, error) {
+	rc, err := ng.loader.Load(fname)
+	if err != nil {
+		return 0, err
+	}
+	defer rc.Close()
+
+	content, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return 0, err
+	}
+	lines := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++
+	}
+	return lines, nil
+}
+
+// sourcesIn returns the paths of the scripts in dir and its subdirectories, except for declaration files and
+// the contents of node_modules and hidden directories.
+func sourcesIn(dr DirReader, dir string) ([]string, error) {
+	entries, err := dr.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	fnames := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		name := entry.Name()
+		fname := filepath.Join(dir, name)
+		switch {
+		case entry.IsDir():
+			if name == "node_modules" || strings.HasPrefix(name, ".") {
+				continue
+			}
+			sub, err := sourcesIn(dr, fname)
+			if err != nil {
+				return nil, err
+			}
+			fnames = append(fnames, sub...)
+		case filepath.Ext(name) != "" && script.IsScript(name) && !strings.HasSuffix(name, ".d.ts"):
+			fnames = append(fnames, fname)
+		}
+	}
+	return fnames, nil
+}
@@ -0,0 +1,71 @@
+package engine
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mkock/esclean/engine/loaders"
+)
+
+func TestEngineWithOrphans(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": `
+import './polyfill'
+import { hackPentagon } from './firstFile'
+`,
+		"/projectA/polyfill.js": "window.hacked = true\n",
+		"/projectA/firstFile.js": `
+export function hackPentagon() {
+    return 'Hacked!'
+}`,
+		"/projectA/old/legacy.js":                "export function legacy() {\n}\n",
+		"/projectA/old/legacy.test.ts":           "import { legacy } from './legacy'\nlegacy()",
+		"/projectA/types/globals.d.ts":           "declare const VERSION: string\n",
+		"/projectA/styles.css":                   ".button {}\n",
+		"/projectA/node_modules/dep/index.js":    "export default 42\n",
+		"/projectA/.storybook/main.js":           "module.exports = {}\n",
+		"/projectA/build/bundle.js":              "console.log('Bundled!')\n",
+		"/projectA/build/nested/bundle.chunk.js": "console.log('Chunked!')\n",
+	}
+	exload := loaders.NewExcludeLoader(loaders.NewMemLoader(fileset), "/projectA", []string{"build/"}, false)
+	ng := New("/projectA/index.js", exload, WithOrphans("/projectA"))
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Orphan{
+		{File: "./old/legacy.js", Lines: 2},
+		{File: "./old/legacy.test.ts", Lines: 2},
+	}
+	if !reflect.DeepEqual(report.Orphans, expected) {
+		t.Fatalf("Expected %v, got %v", expected, report.Orphans)
+	}
+	if report.OrphanLines != 4 {
+		t.Fatalf("Expected 4 orphan lines, got %d", report.OrphanLines)
+	}
+}
+
+func TestEngineWithOrphansAboveEntries(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/src/index.js":     "import { helper } from './helper'\n",
+		"/projectA/src/helper.js":    "export function helper() {\n}\n",
+		"/projectA/src/unused.js":    "export function unused() {\n}\n",
+		"/projectA/scripts/tool.ts":  "console.log('Tooling!')\n",
+		"/projectA/jest.config.js":   "module.exports = {}\n",
+		"/projectA/src/lib/extra.ts": "export const extra = 1\n",
+	}
+	ng := New("/projectA/src/index.js", loaders.NewMemLoader(fileset), WithOrphans("/projectA"))
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []Orphan{
+		{File: "./jest.config.js", Lines: 1},
+		{File: "./scripts/tool.ts", Lines: 1},
+		{File: "./src/lib/extra.ts", Lines: 1},
+		{File: "./src/unused.js", Lines: 2},
+	}
+	if !reflect.DeepEqual(report.Orphans, expected) {
+		t.Fatalf("Expected %v, got %v", expected, report.Orphans)
+	}
+}
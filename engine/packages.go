@@ -0,0 +1,214 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/mkock/esclean/script"
+)
+
+// packageJSON contains the fields of a package.json file that are relevant for finding a package's files.
+type packageJSON struct {
+	Main    string          `json:"main"`
+	Module  string          `json:"module"`
+	Exports json.RawMessage `json:"exports"`
+}
+
+// loadPackageJSON reads and returns the package.json file fname, using loader.
+func loadPackageJSON(loader SourceLoader, fname string) (*packageJSON, error) {
+	var pkg packageJSON
+	if err := loadJSON(loader, fname, &pkg); err != nil {
+		return nil, err
+	}
+	return &pkg, nil
+}
+
+// loadJSON reads the JSON file fname into v, using loader.
+func loadJSON(loader SourceLoader, fname string, v interface{}) error {
+	rc, err := loader.Load(fname)
+	if err != nil {
+		return err
+	}
+	defer rc.Close()
+
+	if err = json.NewDecoder(rc).Decode(v); err != nil {
+		return fmt.Errorf("unable to read %q: %s", fname, err)
+	}
+	return nil
+}
+
+// entry returns the path of the file that is loaded when the package is imported by its name, relative to the
+// package directory. The "." entry of "exports" is preferred to "module", which is preferred to "main".
+func (pkg *packageJSON) entry() string {
+	if entries := exportPaths(pkg.Exports); len(entries) > 0 {
+		return entries[0]
+	}
+	if pkg.Module != "" {
+		return pkg.Module
+	}
+	if pkg.Main != "" {
+		return pkg.Main
+	}
+	return "index"
+}
+
+// exportPaths returns the file paths of the main entry (".") of the "exports" field of a package.json file,
+// with "import" conditions first.
+func exportPaths(exports json.RawMessage) []string {
+	if len(exports) == 0 {
+		return nil
+	}
+
+	var str string
+	if err := json.Unmarshal(exports, &str); err == nil {
+		return []string{str}
+	}
+	var conds map[string]json.RawMessage
+	if err := json.Unmarshal(exports, &conds); err != nil {
+		return nil
+	}
+
+	// Sub path exports have keys starting with ".", ie. "./utils", while conditions don't.
+	keys := make([]string, 0, len(conds))
+	for key := range conds {
+		if strings.HasPrefix(key, ".") && key != "." {
+			continue
+		}
+		keys = append(keys, key)
+	}
+	sort.Slice(keys, func(i, j int) bool {
+		if (keys[i] == "import") != (keys[j] == "import") {
+			return keys[i] == "import"
+		}
+		return keys[i] < keys[j]
+	})
+
+	paths := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if strings.Contains(key, "*") {
+			continue
+		}
+		paths = append(paths, exportPaths(conds[key])...)
+	}
+	return paths
+}
+
+// A PackageResolver follows imports of a selected set of packages into the nearest node_modules directory, so
+// that local libraries, ie. ones that are linked there, can be analysed along with the project that uses them.
+// Imports of other packages are left alone and are thus considered external.
+type PackageResolver struct {
+	loader SourceLoader
+	names  map[string]bool
+	mu     sync.Mutex
+	roots  map[string]string // Package name -> package directory.
+}
+
+// NewPackageResolver returns a new PackageResolver that follows imports of the packages with the given names.
+func NewPackageResolver(loader SourceLoader, names ...string) *PackageResolver {
+	pkgs := PackageResolver{loader: loader, names: make(map[string]bool, len(names)), roots: make(map[string]string)}
+	for _, name := range names {
+		pkgs.names[name] = true
+	}
+	return &pkgs
+}
+
+// ResolveSpecifier returns the path of the file that spec refers to, if spec refers to one of the selected packages.
+// Just like Node.js, it looks for the package in the node_modules directory of each parent directory of importer.
+func (pkgs *PackageResolver) ResolveSpecifier(spec, importer string) string {
+	name, sub := splitPackage(spec)
+	if !pkgs.names[name] {
+		return ""
+	}
+
+	for dir := filepath.Dir(importer); ; dir = filepath.Dir(dir) {
+		// Linked packages may be canonicalised by the loader, so we use the directory of the resolved file.
+		if manifest := pkgs.loader.Resolve(filepath.Join(dir, "node_modules", name, "package.json")); manifest != "" {
+			root := filepath.Dir(manifest)
+			pkgs.mu.Lock()
+			pkgs.roots[name] = root
+			pkgs.mu.Unlock()
+
+			if sub != "" {
+				return filepath.Join(root, sub)
+			}
+			pkg, err := loadPackageJSON(pkgs.loader, manifest)
+			if err != nil {
+				return ""
+			}
+			return filepath.Join(root, pkg.entry())
+		}
+		if parent := filepath.Dir(dir); parent == dir {
+			return ""
+		}
+	}
+}
+
+// packageOf returns the name and directory of the followed package that contains the file fname, if any.
+func (pkgs *PackageResolver) packageOf(fname string) (string, string) {
+	pkgs.mu.Lock()
+	defer pkgs.mu.Unlock()
+	for name, root := range pkgs.roots {
+		if strings.HasPrefix(fname, root+string(filepath.Separator)) {
+			return name, root
+		}
+	}
+	return "", ""
+}
+
+// A PackageReport contains the usage of the exports of a package that was followed into node_modules.
+type PackageReport struct {
+	Name                       string
+	UsedExports, UnusedExports []string
+}
+
+// createPackageReports returns a PackageReport for each followed package, sorted by package name.
+// Exports are formatted like Report.Results, but with paths relative to the package directory.
+func (ng *Engine) createPackageReports() []PackageReport {
+	reports := make(map[string]*PackageReport)
+	for fname, file := range ng.tree {
+		name, root := ng.packages.packageOf(fname)
+		if name == "" {
+			continue
+		}
+		rep, ok := reports[name]
+		if !ok {
+			rep = &PackageReport{Name: name}
+			reports[name] = rep
+		}
+		rel, err := filepath.Rel(root, fname)
+		if err != nil {
+			rel = fname
+		}
+		for _, exp := range file.Exports {
+			txt := fmt.Sprintf("%s:%d %q\n", filepath.ToSlash(filepath.Join(name, rel)), exp.Line, exp.Signature)
+			if exp.RefCount == 0 {
+				rep.UnusedExports = append(rep.UnusedExports, txt)
+			} else {
+				rep.UsedExports = append(rep.UsedExports, txt)
+			}
+		}
+	}
+
+	sorted := make([]PackageReport, 0, len(reports))
+	for _, rep := range reports {
+		sort.Strings(rep.UsedExports)
+		sort.Strings(rep.UnusedExports)
+		sorted = append(sorted, *rep)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Name < sorted[j].Name })
+
+	return sorted
+}
+
+// isPackageExport returns true if exp belongs to a file of a followed package.
+func (ng *Engine) isPackageExport(exp *script.ExportStmt) bool {
+	if ng.packages == nil {
+		return false
+	}
+	name, _ := ng.packages.packageOf(exp.FileRef.RelPath)
+	return name != ""
+}
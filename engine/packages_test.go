@@ -0,0 +1,68 @@
+package engine
+
+import (
+	"encoding/json"
+	"reflect"
+	"testing"
+
+	"github.com/mkock/esclean/engine/loaders"
+)
+
+func TestPackageEntry(t *testing.T) {
+	cases := map[string]string{
+		`{}`:                        "index",
+		`{"main": "./lib/main.js"}`: "./lib/main.js",
+		`{"main": "main.js", "module": "./esm/index.js"}`:                                                  "./esm/index.js",
+		`{"main": "main.js", "exports": "./dist/index.js"}`:                                                "./dist/index.js",
+		`{"exports": {".": {"require": "./index.cjs", "import": "./index.mjs"}, "./utils": "./utils.js"}}`: "./index.mjs",
+		`{"exports": {"import": "./index.mjs", "default": "./index.js"}}`:                                  "./index.mjs",
+	}
+
+	for in, expected := range cases {
+		var pkg packageJSON
+		if err := json.Unmarshal([]byte(in), &pkg); err != nil {
+			t.Fatal(err)
+		}
+		if actual := pkg.entry(); actual != expected {
+			t.Fatalf("entry() of %s: expected %q, got %q", in, expected, actual)
+		}
+	}
+}
+
+func TestEngineWithPackages(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/src/index.js": `
+import { Button } from 'my-lib'
+import { formatDate } from 'my-lib/utils'
+import React from 'react'
+`,
+		"/projectA/node_modules/my-lib/package.json": `{"name": "my-lib", "module": "./index.js"}`,
+		"/projectA/node_modules/my-lib/index.js":     "export function Button() {\n}\nexport function Input() {\n}",
+		"/projectA/node_modules/my-lib/utils.js":     "export function formatDate() {\n}",
+		"/projectA/node_modules/react/package.json":  `{"name": "react", "main": "index.js"}`,
+		"/projectA/node_modules/react/index.js":      "export default React",
+	}
+	memload := loaders.NewMemLoader(fileset)
+	ng := New("/projectA/src/index.js", memload, WithPackages("my-lib"))
+	report, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesChecked != 3 {
+		t.Fatalf("Expected 3 checked files, got %d", report.FilesChecked)
+	}
+	if report.UnusedExports != 0 {
+		t.Fatalf("Expected 0 unused exports in the project, got %d", report.UnusedExports)
+	}
+	expected := []PackageReport{{
+		Name:          "my-lib",
+		UsedExports:   []string{"my-lib/index.js:1 \"export function Button()\"\n", "my-lib/utils.js:1 \"export function formatDate()\"\n"},
+		UnusedExports: []string{"my-lib/index.js:3 \"export function Input()\"\n"},
+	}}
+	if !reflect.DeepEqual(report.Packages, expected) {
+		t.Fatalf("Expected %q, got %q", expected, report.Packages)
+	}
+	if !reflect.DeepEqual(report.Externals, []string{"react"}) {
+		t.Fatalf("Expected externals %q, got %q", []string{"react"}, report.Externals)
+	}
+}
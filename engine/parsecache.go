@@ -0,0 +1,162 @@
+package engine
+
+import (
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mkock/esclean/script"
+)
+
+// parseCacheVersion is the version of the cache entry format. Bump it whenever the parser or the format changes,
+// so entries written by older versions are ignored.
+const parseCacheVersion = 1
+
+// A parseCache persists parsed script.Files in a directory, so unchanged files needn't be parsed again on the
+// next run. There is a single entry per path, which also holds a hash of the contents that it was parsed from.
+// Entries are replaced when the contents change. It is safe to use a parseCache concurrently.
+type parseCache struct {
+	dir string
+}
+
+// A cacheEntry is the JSON representation of a parsed script.File. Statement hashes aren't stored, since they
+// are recomputed when the entry is decoded.
+type cacheEntry struct {
+	Version        int
+	Path           string
+	ContentHash    uint64
+	Imports        []cachedImport
+	BareImports    []cachedImport
+	References     []cachedImport
+	Exports        []cachedExport
+	AmbientModules []string
+}
+
+type cachedImport struct {
+	Line                            int
+	Name, RelPath, Namespace, Local string
+	Default, TopLevel               bool
+}
+
+type cachedExport struct {
+	Line            int
+	Name, Signature string
+	Uses            []string
+}
+
+// contentHash returns the hash of the file contents that cache entries are validated with.
+func contentHash(content []byte) uint64 {
+	h := fnv.New64a()
+	h.Write(content)
+	return h.Sum64()
+}
+
+// entryPath returns the path of the cache entry for the file fname.
+func (pc *parseCache) entryPath(fname string) string {
+	h := fnv.New64a()
+	h.Write([]byte(fname))
+	return filepath.Join(pc.dir, fmt.Sprintf("%016x.json", h.Sum64()))
+}
+
+// get returns the cached result of parsing fname, or nil if there is no entry for the given contents.
+// Entries that can't be read are treated as missing.
+func (pc *parseCache) get(fname string, content []byte) *script.File {
+	data, err := ioutil.ReadFile(pc.entryPath(fname))
+	if err != nil {
+		return nil
+	}
+	var entry cacheEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		return nil
+	}
+	if entry.Version != parseCacheVersion || entry.Path != fname || entry.ContentHash != contentHash(content) {
+		return nil
+	}
+	return entry.file()
+}
+
+// put stores the result of parsing fname with the given contents. The entry is written to a temporary file
+// first, so readers never see a partial entry.
+func (pc *parseCache) put(fname string, content []byte, fi *script.File) error {
+	data, err := json.Marshal(newCacheEntry(fi, contentHash(content)))
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(pc.dir, 0755); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(pc.dir, "entry")
+	if err != nil {
+		return err
+	}
+	if _, err = tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err = tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), pc.entryPath(fname))
+}
+
+// newCacheEntry returns the cache entry for fi.
+func newCacheEntry(fi *script.File, hash uint64) cacheEntry {
+	entry := cacheEntry{
+		Version: parseCacheVersion, Path: fi.RelPath, ContentHash: hash, Imports: cachedImports(fi.Imports),
+		BareImports: cachedImports(fi.BareImports), AmbientModules: fi.AmbientModules,
+	}
+	for _, ref := range fi.References {
+		entry.References = append(entry.References, cachedImport{Line: ref.Line, RelPath: ref.RelPath})
+	}
+	for _, exp := range fi.Exports {
+		entry.Exports = append(entry.Exports, cachedExport{Line: exp.Line, Name: exp.Name, Signature: exp.Signature, Uses: exp.Uses})
+	}
+	return entry
+}
+
+// cachedImports returns the cache representation of the given imports.
+func cachedImports(imps map[uint64]*script.ImportStmt) []cachedImport {
+	cached := make([]cachedImport, 0, len(imps))
+	for _, imp := range imps {
+		cached = append(cached, cachedImport{
+			Line: imp.Line, Name: imp.Name, RelPath: imp.RelPath, Namespace: imp.Namespace, Local: imp.Local,
+			Default: imp.Default, TopLevel: imp.TopLevel,
+		})
+	}
+	return cached
+}
+
+// file returns the script.File that the entry represents, as if it was just parsed.
+func (entry cacheEntry) file() *script.File {
+	fi := script.NewFile(entry.Path)
+	for _, imp := range entry.Imports {
+		stmt := imp.stmt(fi)
+		fi.Imports[stmt.Hash(fi.RelPath)] = stmt
+	}
+	for _, imp := range entry.BareImports {
+		stmt := imp.stmt(fi)
+		fi.BareImports[stmt.Hash(fi.RelPath)] = stmt
+	}
+	for _, ref := range entry.References {
+		fi.References = append(fi.References, ref.stmt(fi))
+	}
+	for _, exp := range entry.Exports {
+		stmt := &script.ExportStmt{FileRef: fi, Line: exp.Line, Name: exp.Name, Signature: exp.Signature, Uses: exp.Uses}
+		fi.Exports[stmt.Hash(fi.RelPath)] = stmt
+	}
+	fi.AmbientModules = entry.AmbientModules
+	return fi
+}
+
+// stmt returns the ImportStmt that imp represents, as part of the file fi.
+func (imp cachedImport) stmt(fi *script.File) *script.ImportStmt {
+	return &script.ImportStmt{
+		FileRef: fi, Line: imp.Line, Name: imp.Name, RelPath: imp.RelPath, Namespace: imp.Namespace,
+		Local: imp.Local, Default: imp.Default, TopLevel: imp.TopLevel,
+	}
+}
@@ -0,0 +1,109 @@
+package engine
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mkock/esclean/engine/loaders"
+	"github.com/mkock/esclean/script"
+)
+
+func TestCacheEntry(t *testing.T) {
+	src := `/// <reference path="./globals.d.ts" />
+import React from 'react'
+import { hackNSA, hackCIA as cia } from './secondFile'
+import * as utils from './utils'
+
+declare module 'legacy' {
+}
+
+export function hackPentagon() {
+    return hackNSA(utils.target)
+}
+export const hackFBI = () => cia()
+console.log(React)
+`
+	parsed, err := script.Parse(strings.NewReader(src), "/projectA/firstFile.ts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(newCacheEntry(parsed, contentHash([]byte(src))))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var entry cacheEntry
+	if err = json.Unmarshal(data, &entry); err != nil {
+		t.Fatal(err)
+	}
+	if cached := entry.file(); !reflect.DeepEqual(cached, parsed) {
+		t.Fatalf("Expected the cached file to equal the parsed file %+v, got %+v", parsed, cached)
+	}
+}
+
+func TestEngineWithCacheDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "esclean")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	fileset := map[string]string{
+		"/projectA/index.js": `
+import { hackPentagon } from './firstFile'
+`,
+		"/projectA/firstFile.js": `
+export function hackPentagon() {
+    return 'Hacked!'
+}
+export function hackNSA() {
+    return 'Hacked!'
+}`,
+	}
+	start := func() Report {
+		ng := New("/projectA/index.js", loaders.NewMemLoader(fileset), WithCacheDir(dir))
+		report, err := ng.Start()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return report
+	}
+
+	first := start()
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected 2 cache entries, got %d", len(entries))
+	}
+	if second := start(); !reflect.DeepEqual(first, second) {
+		t.Fatalf("Expected the same report from the cache, got %v and %v", first, second)
+	}
+
+	t.Run("reuses unchanged files", func(t *testing.T) {
+		// Replace the entry with a different result for the same contents, so we can tell that it is used.
+		pc := parseCache{dir: dir}
+		fi := script.NewFile("/projectA/firstFile.js")
+		exp := &script.ExportStmt{FileRef: fi, Line: 1, Name: "hackCIA", Signature: "export function hackCIA()"}
+		fi.Exports[exp.Hash(fi.RelPath)] = exp
+		if err := pc.put(fi.RelPath, []byte(fileset[fi.RelPath]), fi); err != nil {
+			t.Fatal(err)
+		}
+		report := start()
+		expected := []string{"./firstFile.js:1 \"export function hackCIA()\"\n"}
+		if !reflect.DeepEqual(report.Results, expected) {
+			t.Fatalf("Expected %q, got %q", expected, report.Results)
+		}
+	})
+
+	t.Run("parses changed files", func(t *testing.T) {
+		fileset["/projectA/firstFile.js"] = "export function hackPentagon() {\n}\n"
+		if report := start(); report.UnusedExports != 0 {
+			t.Fatalf("Expected 0 unused exports, got %q", report.Results)
+		}
+	})
+}
@@ -0,0 +1,93 @@
+package engine
+
+// An EventKind tells what an Event reports.
+type EventKind uint8
+
+// Kinds of progress events.
+const (
+	// EventPhase is sent when the Engine enters a new Phase.
+	EventPhase EventKind = iota
+	// EventDiscovered is sent when a file is discovered and queued to be visited.
+	EventDiscovered
+	// EventParsed is sent when a file has been loaded, parsed and added to the FileTree.
+	EventParsed
+)
+
+// A Phase is a step of the analysis.
+type Phase uint8
+
+// Phases of the analysis, in the order that they occur. PhaseLiveness and PhaseOrphans are skipped unless
+// WithTransitive and WithOrphans are used. PhaseDone is entered last, even if the analysis fails or is cancelled.
+const (
+	PhaseTraverse Phase = iota
+	PhaseRefCounts
+	PhaseLiveness
+	PhaseOrphans
+	PhaseDone
+)
+
+// String returns a short description of the phase.
+func (p Phase) String() string {
+	switch p {
+	case PhaseTraverse:
+		return "parsing files"
+	case PhaseRefCounts:
+		return "counting references"
+	case PhaseLiveness:
+		return "finding transitively unused exports"
+	case PhaseOrphans:
+		return "finding orphan files"
+	case PhaseDone:
+		return "done"
+	}
+	return "unknown phase"
+}
+
+// An Event reports the progress of an analysis. Every event carries the current phase and file counts, so the
+// latest event is enough to render the progress.
+type Event struct {
+	Kind       EventKind
+	Phase      Phase
+	File       string // The discovered or parsed file, if any.
+	Discovered int    // The number of files discovered so far.
+	Parsed     int    // The number of files parsed so far.
+	Queued     int    // The number of discovered files that are waiting to be parsed.
+}
+
+// progress keeps track of the progress of an analysis and sends events to the callback set by WithProgress.
+// Events are sent from a single goroutine, in order.
+type progress struct {
+	fn         func(Event)
+	phase      Phase
+	discovered int
+	parsed     int
+}
+
+// send sends an event of the given kind about fname to the callback, if there is one.
+func (p *progress) send(kind EventKind, fname string) {
+	if p.fn == nil {
+		return
+	}
+	p.fn(Event{
+		Kind: kind, Phase: p.phase, File: fname, Discovered: p.discovered, Parsed: p.parsed,
+		Queued: p.discovered - p.parsed,
+	})
+}
+
+// enter records that the analysis has entered the given phase.
+func (p *progress) enter(phase Phase) {
+	p.phase = phase
+	p.send(EventPhase, "")
+}
+
+// discover records that fname has been discovered.
+func (p *progress) discover(fname string) {
+	p.discovered++
+	p.send(EventDiscovered, fname)
+}
+
+// parse records that fname has been parsed.
+func (p *progress) parse(fname string) {
+	p.parsed++
+	p.send(EventParsed, fname)
+}
@@ -1,7 +1,9 @@
 package engine
 
 import (
+	"context"
 	"io"
+	"io/fs"
 )
 
 // A SourceLoader provides file contents based on a given filename and path.
@@ -9,3 +11,30 @@ type SourceLoader interface {
 	Resolve(fname string) string
 	Load(fname string) (io.ReadCloser, error)
 }
+
+// A DirReader is a SourceLoader that is also able to list the contents of a directory.
+type DirReader interface {
+	ReadDir(dir string) ([]fs.DirEntry, error)
+}
+
+// A SpecifierResolver maps bare import specifiers, such as package names and URLs, to project files.
+type SpecifierResolver interface {
+	// ResolveSpecifier returns the path of the file that spec refers to when imported by the file importer.
+	// The path may omit the file extension, just like relative import paths. Returns an empty string
+	// if spec isn't handled by the resolver.
+	ResolveSpecifier(spec, importer string) string
+}
+
+// A ContextLoader is a SourceLoader that is able to stop loading a file when ctx is cancelled, ie. one that
+// shells out to another program. Only loading is cancellable: Resolve and ReadDir have no context, so a call
+// that has started always completes, and the engine only checks ctx between them. Loaders should therefore do
+// any slow work, such as reading a whole archive or listing a git revision, up front or in LoadContext.
+type ContextLoader interface {
+	LoadContext(ctx context.Context, fname string) (io.ReadCloser, error)
+}
+
+// An Excluder is a SourceLoader that excludes some of the files that it is able to resolve, ie. build output or
+// files listed in .gitignore. Imports of excluded files are dropped silently, rather than reported as unresolved.
+type Excluder interface {
+	Excluded(fname string) bool
+}
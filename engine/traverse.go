@@ -0,0 +1,298 @@
+package engine
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+
+	"github.com/mkock/esclean/script"
+)
+
+// A visit is the outcome of loading and parsing a single file and resolving its import statements.
+// Visits are made concurrently, so the findings are kept here until the visit is committed to the Engine.
+type visit struct {
+	seq        int
+	file       *script.File
+	companion  string
+	unresolved []UnresolvedImport
+	mismatches []CaseMismatch
+	err        error
+}
+
+// A job is a file that is waiting to be visited, numbered in order of discovery.
+type job struct {
+	seq   int
+	fname string
+}
+
+// traverse visits the given files and follows their imports recursively, while visiting each file exactly once.
+// Files are loaded and parsed by a pool of workers, while all Engine state is updated here, in the order that the
+// files were discovered. This makes the result the same regardless of the number of workers.
+// Returns ctx.Err() if ctx is cancelled before all files are visited.
+func (ng *Engine) traverse(ctx context.Context, fnames []string) error {
+	workers := ng.workers
+	if workers < 1 {
+		workers = 1
+	}
+	jobs := make(chan job)
+	results := make(chan visit)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for j := range jobs {
+				results <- ng.visit(ctx, j.seq, j.fname)
+			}
+		}()
+	}
+
+	var (
+		pending  []job // Files that are waiting for a worker.
+		inflight int
+		seq      int
+		err      error
+	)
+	// Files that are already in the FileTree have been visited before, ie. when updating the analysis.
+	seen := make(map[string]bool, 100)
+	enqueue := func(fname string) {
+		if _, ok := ng.tree[fname]; !ok && !seen[fname] {
+			seen[fname] = true
+			pending = append(pending, job{seq: seq, fname: fname})
+			seq++
+			ng.progress.discover(fname)
+		}
+	}
+	for _, fname := range fnames {
+		enqueue(fname)
+	}
+
+	// Visits are committed in order of discovery; done holds the ones that finished out of order.
+	done := make(map[int]visit)
+	var next int
+	for next < seq && err == nil {
+		var (
+			send chan job
+			j    job
+		)
+		if len(pending) > 0 {
+			send, j = jobs, pending[0]
+		}
+
+		select {
+		case send <- j:
+			pending = pending[1:]
+			inflight++
+		case res := <-results:
+			inflight--
+			done[res.seq] = res
+			for res, ok := done[next]; ok; res, ok = done[next] {
+				delete(done, next)
+				next++
+				if err = res.err; err != nil {
+					break
+				}
+				deps := ng.commit(res)
+				ng.progress.parse(res.file.RelPath)
+				for _, dep := range deps {
+					enqueue(dep)
+				}
+			}
+		case <-ctx.Done():
+			err = ctx.Err()
+		}
+	}
+
+	// Let the workers finish what they're doing, in case we stopped early.
+	close(jobs)
+	for ; inflight > 0; inflight-- {
+		<-results
+	}
+	return err
+}
+
+// commit records the visit in the Engine and returns the paths of the files that the visited file depends on.
+func (ng *Engine) commit(v visit) []string {
+	fi := v.file
+	ng.tree[fi.RelPath] = fi
+	ng.unresolved = append(ng.unresolved, v.unresolved...)
+	ng.mismatches = append(ng.mismatches, v.mismatches...)
+
+	// Register ambient module declarations. The first declaration wins.
+	for _, name := range fi.AmbientModules {
+		if _, ok := ng.ambient[name]; !ok {
+			ng.ambient[name] = fi.RelPath
+		}
+	}
+
+	// Declaration files and their implementations are visited together.
+	deps := make([]string, 0, len(fi.Imports)+len(fi.References)+1)
+	if v.companion != "" {
+		ng.companions[fi.RelPath] = v.companion
+		deps = append(deps, v.companion)
+	}
+
+	imps := make([]*script.ImportStmt, 0, len(fi.Imports))
+	for _, imp := range fi.Imports {
+		imps = append(imps, imp)
+	}
+	sort.Slice(imps, func(i, j int) bool {
+		if imps[i].Line != imps[j].Line {
+			return imps[i].Line < imps[j].Line
+		}
+		return imps[i].RelPath < imps[j].RelPath
+	})
+	for _, imp := range imps {
+		deps = append(deps, imp.RelPath)
+	}
+	for _, ref := range fi.References {
+		deps = append(deps, ref.RelPath)
+	}
+
+	return deps
+}
+
+// visit loads the contents of the resolved file fname, parses them into a script.File and resolves its import
+// statements. It is safe to call visit concurrently, as it doesn't change the Engine.
+func (ng *Engine) visit(ctx context.Context, seq int, fname string) visit {
+	v := visit{seq: seq, file: script.NewFile(fname)}
+
+	// Load and parse the file.
+	fi, err := ng.parse(ctx, fname)
+	if err != nil {
+		v.err = err
+		return v
+	}
+	v.file = fi
+
+	// Resolve each import statement.
+	seen := make(map[UnresolvedImport]bool)
+	mismatches := make(map[CaseMismatch]bool)
+	for key, imp := range fi.Imports {
+		impFile := filepath.Join(filepath.Dir(fname), imp.RelPath)
+
+		// Prefer the correct casing, so the file is resolved on case-sensitive file systems as well, and
+		// so it will always have the same identity in the FileTree.
+		if corrected := ng.correctCase(impFile, filepath.Dir(fname)); corrected != impFile {
+			mismatch := CaseMismatch{File: ng.relPath(fname), Line: imp.Line, Path: imp.RelPath, Suggestion: suggestPath(imp.RelPath, corrected)}
+			if !mismatches[mismatch] {
+				mismatches[mismatch] = true
+				v.mismatches = append(v.mismatches, mismatch)
+			}
+			impFile = corrected
+		}
+
+		resImpFile := ng.loader.Resolve(impFile)
+		if resImpFile == "" {
+			if v.err = ng.skipUnresolved(&v, imp, seen); v.err != nil {
+				return v
+			}
+			delete(fi.Imports, key)
+			continue
+		}
+		if ng.excluded(resImpFile) {
+			delete(fi.Imports, key)
+			continue
+		}
+		imp.RelPath = resImpFile
+	}
+
+	// Map bare imports to project files, if possible. The rest are external.
+	for key, imp := range fi.BareImports {
+		impFile := ng.resolveSpecifier(imp.RelPath, fname)
+		if impFile == "" {
+			continue
+		}
+		delete(fi.BareImports, key)
+
+		resImpFile := ng.loader.Resolve(impFile)
+		if resImpFile == "" {
+			if v.err = ng.skipUnresolved(&v, imp, seen); v.err != nil {
+				return v
+			}
+			continue
+		}
+		if ng.excluded(resImpFile) {
+			continue
+		}
+		imp.RelPath = resImpFile
+		fi.Imports[key] = imp
+	}
+
+	// Resolve each reference directive.
+	refs := fi.References[:0]
+	for _, ref := range fi.References {
+		resRefFile := ng.loader.Resolve(filepath.Join(filepath.Dir(fname), ref.RelPath))
+		if resRefFile == "" {
+			if v.err = ng.skipUnresolved(&v, ref, seen); v.err != nil {
+				return v
+			}
+			continue
+		}
+		if ng.excluded(resRefFile) {
+			continue
+		}
+		ref.RelPath = resRefFile
+		refs = append(refs, ref)
+	}
+	fi.References = refs
+
+	v.companion = ng.companionOf(fname)
+	return v
+}
+
+// parse loads and parses fname. Scripts are looked up in the parse cache first, if there is one, and added to it
+// when they have been parsed. Errors from the cache are ignored, since the file can always be parsed instead.
+func (ng *Engine) parse(ctx context.Context, fname string) (*script.File, error) {
+	rc, err := ng.load(ctx, fname)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	if ng.cache == nil || !script.IsScript(fname) {
+		return script.Parse(rc, fname)
+	}
+
+	content, err := ioutil.ReadAll(rc)
+	if err != nil {
+		return nil, err
+	}
+	if fi := ng.cache.get(fname, content); fi != nil {
+		return fi, nil
+	}
+	fi, err := script.Parse(bytes.NewReader(content), fname)
+	if err != nil {
+		return nil, err
+	}
+	ng.cache.put(fname, content, fi)
+	return fi, nil
+}
+
+// load loads fname, using LoadContext if the loader supports it.
+func (ng *Engine) load(ctx context.Context, fname string) (io.ReadCloser, error) {
+	if cl, ok := ng.loader.(ContextLoader); ok {
+		return cl.LoadContext(ctx, fname)
+	}
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+	return ng.loader.Load(fname)
+}
+
+// skipUnresolved applies the UnresolvedPolicy to the import statement imp, which could not be resolved.
+// Named imports from the same statement share the same UnresolvedImport, so seen is used to deduplicate them.
+func (ng *Engine) skipUnresolved(v *visit, imp *script.ImportStmt, seen map[UnresolvedImport]bool) error {
+	switch ng.unresolvedPolicy {
+	case UnresolvedFail:
+		return fmt.Errorf("unable to resolve file %q", imp.RelPath)
+	case UnresolvedWarn:
+		unres := UnresolvedImport{File: ng.relPath(v.file.RelPath), Line: imp.Line, Path: imp.RelPath}
+		if !seen[unres] {
+			seen[unres] = true
+			v.unresolved = append(v.unresolved, unres)
+		}
+	}
+	return nil
+}
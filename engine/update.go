@@ -0,0 +1,230 @@
+package engine
+
+import (
+	"context"
+	"fmt"
+	"sort"
+)
+
+// Update re-analyses the project after the given files have changed, ie. in a watch mode. Changed files are
+// parsed again, and any new imports are followed, while the rest of the FileTree is kept. Files that no longer
+// exist are removed, and their importers are parsed again so their imports are resolved anew. Files with
+// unresolved imports are parsed again too, so call Update without any files when files may have been added.
+// Files that are no longer reachable from the entry files are removed as well. If the update fails, ie. because
+// a changed file has an import that can't be resolved or because ctx is cancelled, the FileTree is restored to what
+// it was before, so the same files can be updated again later. No partial Report is returned. Update must not be
+// called before Start has returned. If the loader caches the results of Resolve, the cache should be reset first.
+func (ng *Engine) Update(ctx context.Context, fnames ...string) (Report, error) {
+	for _, entry := range ng.entries {
+		if ng.loader.Resolve(entry) == "" {
+			return Report{}, fmt.Errorf("unable to resolve file %q", entry)
+		}
+	}
+
+	changed := make([]string, 0, len(fnames))
+	seen := make(map[string]bool, len(fnames))
+	add := func(fname string) {
+		if !seen[fname] {
+			seen[fname] = true
+			changed = append(changed, fname)
+		}
+	}
+	// The imports of a file that was deleted, or that declares ambient modules, may resolve differently now,
+	// so its importers are parsed again as well.
+	importers := ng.importers()
+	for _, fname := range fnames {
+		add(fname)
+		if ng.loader.Resolve(fname) != "" && !ng.declaresAmbient(fname) {
+			continue
+		}
+		for _, imp := range importers[fname] {
+			add(imp.FileRef.RelPath)
+		}
+	}
+
+	// Files with unresolved imports are parsed again as well, since the missing files may have been added.
+	unresolved := make(map[string]bool, len(ng.unresolved))
+	for _, unres := range ng.unresolved {
+		unresolved[unres.File] = true
+	}
+	importing := make([]string, 0, len(unresolved))
+	for fname := range ng.tree {
+		if unresolved[ng.relPath(fname)] {
+			importing = append(importing, fname)
+		}
+	}
+	sort.Strings(importing)
+	for _, fname := range importing {
+		add(fname)
+	}
+
+	// Forget what we know about the changed files, and visit the ones that still exist again.
+	snap := ng.snapshot()
+	revisit := make([]string, 0, len(changed))
+	for _, fname := range changed {
+		ng.forget(fname)
+		if ng.loader.Resolve(fname) != "" {
+			revisit = append(revisit, fname)
+		}
+	}
+
+	ng.progress.enter(PhaseTraverse)
+	defer ng.progress.enter(PhaseDone)
+	if err := ng.traverse(ctx, revisit); err != nil {
+		ng.restore(snap)
+		return Report{}, err
+	}
+	ng.prune()
+	return ng.analyse(ctx, false)
+}
+
+// A snapshot holds the findings of the visits made so far, so that they can be restored after a failed Update.
+type snapshot struct {
+	tree                FileTree
+	companions, ambient map[string]string
+	unresolved          []UnresolvedImport
+	mismatches          []CaseMismatch
+}
+
+// snapshot returns a copy of the findings of the visits made so far. The files themselves aren't copied, since
+// visits add files to the FileTree without modifying the ones that are already there.
+func (ng *Engine) snapshot() snapshot {
+	snap := snapshot{
+		tree:       make(FileTree, len(ng.tree)),
+		companions: make(map[string]string, len(ng.companions)),
+		ambient:    make(map[string]string, len(ng.ambient)),
+		unresolved: append([]UnresolvedImport{}, ng.unresolved...),
+		mismatches: append([]CaseMismatch{}, ng.mismatches...),
+	}
+	for fname, file := range ng.tree {
+		snap.tree[fname] = file
+	}
+	for fname, companion := range ng.companions {
+		snap.companions[fname] = companion
+	}
+	for name, decl := range ng.ambient {
+		snap.ambient[name] = decl
+	}
+	return snap
+}
+
+// restore restores the findings of the snapshot snap.
+func (ng *Engine) restore(snap snapshot) {
+	ng.tree, ng.companions, ng.ambient = snap.tree, snap.companions, snap.ambient
+	ng.unresolved, ng.mismatches = snap.unresolved, snap.mismatches
+}
+
+// forget removes the file fname from the FileTree, along with the findings of its visit.
+func (ng *Engine) forget(fname string) {
+	delete(ng.tree, fname)
+	delete(ng.companions, fname)
+	for name, decl := range ng.ambient {
+		if decl == fname {
+			delete(ng.ambient, name)
+			if other := ng.ambientDeclarer(name); other != "" {
+				ng.ambient[name] = other
+			}
+		}
+	}
+
+	rel := ng.relPath(fname)
+	unresolved := ng.unresolved[:0]
+	for _, unres := range ng.unresolved {
+		if unres.File != rel {
+			unresolved = append(unresolved, unres)
+		}
+	}
+	ng.unresolved = unresolved
+	mismatches := ng.mismatches[:0]
+	for _, mismatch := range ng.mismatches {
+		if mismatch.File != rel {
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	ng.mismatches = mismatches
+}
+
+// prune removes the files that are no longer reachable from the entry files from the FileTree, ie. because the
+// imports of a changed file were removed.
+func (ng *Engine) prune() {
+	reachable := make(map[string]bool, len(ng.tree))
+	pending := append([]string{}, ng.entries...)
+	for len(pending) > 0 {
+		fname := pending[len(pending)-1]
+		pending = pending[:len(pending)-1]
+		file, ok := ng.tree[fname]
+		if !ok || reachable[fname] {
+			continue
+		}
+		reachable[fname] = true
+
+		for _, imp := range file.Imports {
+			pending = append(pending, imp.RelPath)
+		}
+		for _, ref := range file.References {
+			pending = append(pending, ref.RelPath)
+		}
+		if companion, ok := ng.companions[fname]; ok {
+			pending = append(pending, companion)
+		}
+	}
+
+	for fname := range ng.tree {
+		if !reachable[fname] {
+			ng.forget(fname)
+		}
+	}
+	for fname, companion := range ng.companions {
+		if !reachable[companion] {
+			delete(ng.companions, fname)
+		}
+	}
+}
+
+// Diff compares the unused exports of two Reports of the project, where next is the current one, and returns the
+// exports that have become unused and those that have become used since prev. Exports are compared by file and
+// signature, since their line numbers change as the code around them is edited. Exports that no longer exist,
+// ie. because they were deleted, are neither.
+func (ng *Engine) Diff(prev, next Report) (unused, used []UnusedExport) {
+	type key struct{ file, signature string }
+	before := make(map[key]int, len(prev.Unused))
+	for _, exp := range prev.Unused {
+		before[key{exp.File, exp.Signature}]++
+	}
+
+	for _, exp := range next.Unused {
+		k := key{exp.File, exp.Signature}
+		if before[k] > 0 {
+			before[k]--
+			continue
+		}
+		unused = append(unused, exp)
+	}
+
+	// The remaining exports were unused before. Those that still exist are now used.
+	lines := make(map[key][]int)
+	for fname, file := range ng.tree {
+		for _, exp := range file.Exports {
+			k := key{ng.relPath(fname), exp.Signature}
+			if before[k] > 0 && exp.RefCount > 0 {
+				lines[k] = append(lines[k], exp.Line)
+			}
+		}
+	}
+	for k, n := range before {
+		sort.Ints(lines[k])
+		for i := 0; i < n && i < len(lines[k]); i++ {
+			used = append(used, UnusedExport{File: k.file, Line: lines[k][i], Signature: k.signature})
+		}
+	}
+	sort.Slice(used, func(i, j int) bool {
+		if used[i].File != used[j].File {
+			return used[i].File < used[j].File
+		}
+		if used[i].Line != used[j].Line {
+			return used[i].Line < used[j].Line
+		}
+		return used[i].Signature < used[j].Signature
+	})
+	return unused, used
+}
@@ -0,0 +1,327 @@
+package engine
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/mkock/esclean/engine/loaders"
+)
+
+// swapLoader is a SourceLoader whose files can be replaced between analyses.
+type swapLoader struct {
+	SourceLoader
+}
+
+func (sl *swapLoader) swap(fileset map[string]string) {
+	sl.SourceLoader = loaders.NewMemLoader(fileset)
+}
+
+func TestEngineUpdate(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": `
+import { hackPentagon } from './firstFile'
+`,
+		"/projectA/firstFile.js": `
+import { hackNSA } from './secondFile'
+
+export function hackPentagon() {
+    return hackNSA()
+}
+export function hackFBI() {
+}`,
+		"/projectA/secondFile.js": `
+export function hackNSA() {
+}
+export function hackCIA() {
+}`,
+	}
+	loader := &swapLoader{}
+	loader.swap(fileset)
+	ng := New("/projectA/index.js", loader, WithUnresolvedPolicy(UnresolvedWarn))
+	prev, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// update replaces the given files and returns the newly unused and newly used exports.
+	update := func(t *testing.T, files map[string]string) (Report, []UnusedExport, []UnusedExport) {
+		fnames := make([]string, 0, len(files))
+		for fname, content := range files {
+			fnames = append(fnames, fname)
+			if content == "" {
+				delete(fileset, fname)
+				continue
+			}
+			fileset[fname] = content
+		}
+		loader.swap(fileset)
+		report, err := ng.Update(context.Background(), fnames...)
+		if err != nil {
+			t.Fatal(err)
+		}
+		unused, used := ng.Diff(prev, report)
+		prev = report
+		return report, unused, used
+	}
+
+	t.Run("parses changed files again", func(t *testing.T) {
+		report, unused, used := update(t, map[string]string{
+			"/projectA/index.js": "import { hackPentagon, hackFBI } from './firstFile'\n",
+		})
+		if report.FilesChecked != 3 {
+			t.Fatalf("Expected 3 checked files, got %d", report.FilesChecked)
+		}
+		if len(unused) != 0 {
+			t.Fatalf("Expected no newly unused exports, got %v", unused)
+		}
+		expected := []UnusedExport{{File: "./firstFile.js", Line: 7, Signature: "export function hackFBI()"}}
+		if !reflect.DeepEqual(used, expected) {
+			t.Fatalf("Expected newly used exports %v, got %v", expected, used)
+		}
+	})
+
+	t.Run("removes unreachable files", func(t *testing.T) {
+		report, unused, used := update(t, map[string]string{
+			"/projectA/firstFile.js": `
+import { hackMI6 } from './thirdFile'
+
+export function hackPentagon() {
+    return hackMI6()
+}
+export function hackFBI() {
+}`,
+			"/projectA/thirdFile.js": `
+export function hackMI6() {
+}
+export function hackKGB() {
+}`,
+		})
+		visited := ng.Tree().Visited()
+		if report.FilesChecked != 3 || (*ng.Tree())["/projectA/secondFile.js"] != nil {
+			t.Fatalf("Expected the second file to be replaced by the third, got %q", visited)
+		}
+		expected := []UnusedExport{{File: "./thirdFile.js", Line: 4, Signature: "export function hackKGB()"}}
+		if !reflect.DeepEqual(unused, expected) {
+			t.Fatalf("Expected newly unused exports %v, got %v", expected, unused)
+		}
+		if len(used) != 0 {
+			t.Fatalf("Expected no newly used exports, got %v", used)
+		}
+	})
+
+	t.Run("removes deleted files", func(t *testing.T) {
+		report, unused, used := update(t, map[string]string{"/projectA/thirdFile.js": ""})
+		if report.FilesChecked != 2 {
+			t.Fatalf("Expected 2 checked files, got %d", report.FilesChecked)
+		}
+		unresolved := []UnresolvedImport{{File: "./firstFile.js", Line: 2, Path: "./thirdFile"}}
+		if !reflect.DeepEqual(report.Unresolved, unresolved) {
+			t.Fatalf("Expected unresolved imports %v, got %v", unresolved, report.Unresolved)
+		}
+		if len(unused) != 0 || len(used) != 0 {
+			t.Fatalf("Expected no changes, got %v and %v", unused, used)
+		}
+	})
+
+	t.Run("resolves added files", func(t *testing.T) {
+		fileset["/projectA/thirdFile.js"] = `
+export function hackMI6() {
+}`
+		loader.swap(fileset)
+		report, err := ng.Update(context.Background())
+		if err != nil {
+			t.Fatal(err)
+		}
+		if report.FilesChecked != 3 || len(report.Unresolved) != 0 {
+			t.Fatalf("Expected 3 checked files and no unresolved imports, got %d and %v", report.FilesChecked, report.Unresolved)
+		}
+	})
+}
+
+func TestEngineUpdateWithError(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": `
+import { hackPentagon } from './firstFile'
+`,
+		"/projectA/firstFile.js": `
+import { hackNSA } from './secondFile'
+
+export function hackPentagon() {
+    return hackNSA()
+}`,
+		"/projectA/secondFile.js": `
+export function hackNSA() {
+}
+export function hackCIA() {
+}`,
+	}
+	loader := &swapLoader{}
+	loader.swap(fileset)
+	ng := New("/projectA/index.js", loader)
+	prev, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	visited := ng.Tree().Visited()
+	sort.Strings(visited)
+
+	// The import of the missing file fails the update, which leaves the FileTree as it was.
+	fileset["/projectA/firstFile.js"] = `
+import { hackMI6 } from './missingFile'
+
+export function hackPentagon() {
+    return hackMI6()
+}`
+	loader.swap(fileset)
+	if _, err = ng.Update(context.Background(), "/projectA/firstFile.js"); err == nil {
+		t.Fatal("Expected an error for the unresolved import")
+	}
+	actual := ng.Tree().Visited()
+	sort.Strings(actual)
+	if !reflect.DeepEqual(actual, visited) {
+		t.Fatalf("Expected the visited files to be restored to %q, got %q", visited, actual)
+	}
+
+	// Once fixed, the same file can be updated again.
+	fileset["/projectA/firstFile.js"] = `
+export function hackPentagon() {
+}`
+	loader.swap(fileset)
+	report, err := ng.Update(context.Background(), "/projectA/firstFile.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.FilesChecked != 2 || len(report.Unresolved) != 0 {
+		t.Fatalf("Expected 2 checked files and no unresolved imports, got %d and %v", report.FilesChecked, report.Unresolved)
+	}
+	if unused, used := ng.Diff(prev, report); len(unused) != 0 || len(used) != 0 {
+		t.Fatalf("Expected no changes, got %v and %v", unused, used)
+	}
+}
+
+func TestEngineUpdateCancelled(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": "import { a } from './a'\n",
+		"/projectA/a.js":     "import { b } from './b'\n\nexport function a() {\n}\n",
+		"/projectA/b.js":     "export function b() {\n}\nexport function unusedB() {\n}\n",
+	}
+	ng := New("/projectA/index.js", loaders.NewMemLoader(fileset))
+	if _, err := ng.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// A cancelled update leaves the FileTree as it was.
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := ng.Update(ctx, "/projectA/a.js", "/projectA/b.js"); err != context.Canceled {
+		t.Fatalf("Expected %v, got %v", context.Canceled, err)
+	}
+	if n := len(ng.Tree().Visited()); n != 3 {
+		t.Fatalf("Expected 3 visited files, got %d", n)
+	}
+
+	report, err := ng.Update(context.Background(), "/projectA/a.js", "/projectA/b.js")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{"./b.js:3 \"export function unusedB()\"\n"}
+	if report.FilesChecked != 3 || !reflect.DeepEqual(report.Results, expected) {
+		t.Fatalf("Expected 3 checked files and results %q, got %d and %q", expected, report.FilesChecked, report.Results)
+	}
+}
+
+func TestEngineUpdateWithAmbientModules(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.ts": `
+/// <reference path="./types.d.ts" />
+/// <reference path="./vendor.d.ts" />
+import { track } from 'analytics'
+
+track()
+`,
+		"/projectA/types.d.ts": `
+declare module 'analytics' {
+	export function track(): void;
+}
+`,
+		"/projectA/vendor.d.ts": `
+declare module 'analytics' {
+	export function track(): void;
+}
+`,
+	}
+	loader := &swapLoader{}
+	loader.swap(fileset)
+	ng := New("/projectA/index.ts", loader)
+	if _, err := ng.Start(); err != nil {
+		t.Fatal(err)
+	}
+
+	// importedFrom returns the path that the import of track resolves to, or the bare specifier.
+	importedFrom := func() string {
+		index := (*ng.Tree())["/projectA/index.ts"]
+		for _, imp := range index.Imports {
+			return imp.RelPath
+		}
+		for _, imp := range index.BareImports {
+			return imp.RelPath
+		}
+		return ""
+	}
+
+	// The other declaration takes over when the first one is removed.
+	decl := importedFrom()
+	other := "/projectA/vendor.d.ts"
+	if decl == other {
+		other = "/projectA/types.d.ts"
+	}
+	fileset[decl] = "export {};\n"
+	loader.swap(fileset)
+	report, err := ng.Update(context.Background(), decl)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if actual := importedFrom(); actual != other || len(report.Externals) != 0 {
+		t.Fatalf("Expected the import to resolve to %q, got %q and externals %v", other, actual, report.Externals)
+	}
+
+	// Without any declaration, the import is external.
+	fileset[other] = "export {};\n"
+	loader.swap(fileset)
+	if report, err = ng.Update(context.Background(), other); err != nil {
+		t.Fatal(err)
+	}
+	if actual := importedFrom(); actual != "analytics" || !reflect.DeepEqual(report.Externals, []string{"analytics"}) {
+		t.Fatalf("Expected an external import of analytics, got %q and externals %v", actual, report.Externals)
+	}
+}
+
+func TestEngineUpdateKeepsPreviousReports(t *testing.T) {
+	fileset := map[string]string{
+		"/projectA/index.js": "import { a } from './a'\nimport { missing1 } from './missing1'\n",
+		"/projectA/a.js":     "import { missing2 } from './missing2'\n\nexport function a() {\n}\n",
+	}
+	loader := &swapLoader{}
+	loader.swap(fileset)
+	ng := New("/projectA/index.js", loader, WithUnresolvedPolicy(UnresolvedWarn))
+	prev, err := ng.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []UnresolvedImport{{File: "./a.js", Line: 1, Path: "./missing2"}, {File: "./index.js", Line: 2, Path: "./missing1"}}
+	if !reflect.DeepEqual(prev.Unresolved, expected) {
+		t.Fatalf("Expected unresolved imports %v, got %v", expected, prev.Unresolved)
+	}
+
+	// Updating the engine must not modify a Report that has already been returned.
+	fileset["/projectA/a.js"] = "export function a() {\n}\n"
+	loader.swap(fileset)
+	if _, err = ng.Update(context.Background(), "/projectA/a.js"); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(prev.Unresolved, expected) {
+		t.Fatalf("Expected the previous unresolved imports to stay %v, got %v", expected, prev.Unresolved)
+	}
+}
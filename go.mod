@@ -1,5 +1,7 @@
 module github.com/mkock/esclean
 
+go 1.16
+
 require (
 	github.com/deckarep/golang-set v1.7.1
 	github.com/mitchellh/hashstructure v1.0.0
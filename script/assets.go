@@ -0,0 +1,185 @@
+package script
+
+import (
+	"io"
+	"io/ioutil"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// ScriptExts are the extensions of the files that Parse treats as scripts, in the order of preference when an
+// import path without an extension is resolved: TypeScript, then declaration files, then JavaScript.
+// Files with other extensions are treated as assets.
+var ScriptExts = []string{".ts", ".tsx", ".mts", ".cts", ".d.ts", ".js", ".jsx", ".mjs", ".cjs"}
+
+// scriptExts contains the extensions of ScriptExts as returned by path.Ext.
+var scriptExts = make(map[string]bool, len(ScriptExts))
+
+// Extensions of the stylesheets that may be used as CSS modules.
+var styleExts = map[string]bool{".css": true, ".scss": true, ".sass": true, ".less": true}
+
+// Regular expressions for stylesheets.
+var (
+	regexpClass, regexpComment *regexp.Regexp
+)
+
+func init() {
+	for _, ext := range ScriptExts {
+		scriptExts[path.Ext(ext)] = true
+	}
+	regexpClass = regexp.MustCompile(`\.(-?[_a-zA-Z][_a-zA-Z0-9-]*)`)
+	regexpComment = regexp.MustCompile(`(?s)/\*.*?\*/`)
+}
+
+// IsScript returns true if fname refers to a JavaScript or TypeScript file. Paths without a file extension
+// are assumed to be scripts.
+func IsScript(fname string) bool {
+	ext := path.Ext(fname)
+	return ext == "" || scriptExts[ext]
+}
+
+// isCSSModule returns true if fname refers to a CSS module, ie. "Button.module.css".
+func isCSSModule(fname string) bool {
+	base := path.Base(fname)
+	return styleExts[path.Ext(base)] && strings.HasSuffix(strings.TrimSuffix(base, path.Ext(base)), ".module")
+}
+
+// parseJSON returns a File with a single default export, which is how bundlers expose JSON files.
+func parseJSON(relPath string) *File {
+	f := NewFile(relPath)
+	exp := &ExportStmt{FileRef: f, Line: 1, Name: "default", Signature: "default"}
+	f.Exports[exp.Hash(relPath)] = exp
+	return f
+}
+
+// parseCSS returns a File with an export for each class name that is defined in a CSS module.
+// Other stylesheets have no exports.
+func parseCSS(r io.Reader, relPath string) (*File, error) {
+	f := NewFile(relPath)
+	if !isCSSModule(relPath) {
+		return f, nil
+	}
+
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return f, err
+	}
+
+	// Blank out comments, but keep their line breaks so line numbers stay intact.
+	css := regexpComment.ReplaceAllStringFunc(string(content), func(comment string) string {
+		return strings.Repeat("\n", strings.Count(comment, "\n"))
+	})
+
+	// Class names are only exported when they are part of a selector, which is the text preceding a "{".
+	// Everything else, such as property values and at-rule preludes, is skipped.
+	var start, lineNr int
+	seen := make(map[string]bool)
+	lineNr = 1
+	for i := 0; i < len(css); i++ {
+		switch css[i] {
+		case '\n':
+			lineNr++
+		case '}', ';':
+			start = i + 1
+		case '{':
+			sel := css[start:i]
+			start = i + 1
+			if strings.HasPrefix(strings.TrimSpace(sel), "@") {
+				continue
+			}
+			// The selector may span several lines; the class name is on the line where it ends.
+			selLineNr := lineNr - strings.Count(sel, "\n")
+			for _, loc := range regexpClass.FindAllStringSubmatchIndex(sel, -1) {
+				name := sel[loc[2]:loc[3]]
+				if seen[name] {
+					continue
+				}
+				seen[name] = true
+				exp := &ExportStmt{FileRef: f, Line: selLineNr + strings.Count(sel[:loc[0]], "\n"), Name: name, Signature: "." + name}
+				f.Exports[exp.Hash(relPath)] = exp
+			}
+		}
+	}
+
+	return f, nil
+}
+
+// A memberUsage records how a CSS module that was imported by default is used within a script.
+type memberUsage struct {
+	members   map[string]bool
+	wholesale bool // True if the module object is used as a whole, ie. passed to a function.
+}
+
+// trackMembers records the usages of name in line, ie. "name.member" or "name['member']".
+func (usage *memberUsage) trackMembers(line, name string) {
+	for offset := 0; ; {
+		i := strings.Index(line[offset:], name)
+		if i < 0 {
+			return
+		}
+		i += offset
+		offset = i + len(name)
+
+		// Make sure that we found the identifier, and not part of another.
+		if i > 0 && (isIdentChar(line[i-1]) || line[i-1] == '.') {
+			continue
+		}
+		rest := line[offset:]
+		if rest != "" && isIdentChar(rest[0]) {
+			continue
+		}
+
+		rest = strings.TrimPrefix(rest, "?")
+		switch {
+		case strings.HasPrefix(rest, "."):
+			end := 1
+			for end < len(rest) && isIdentChar(rest[end]) {
+				end++
+			}
+			if end > 1 {
+				usage.members[rest[1:end]] = true
+				continue
+			}
+		case strings.HasPrefix(rest, "['") || strings.HasPrefix(rest, "[\""):
+			if end := strings.IndexByte(rest[2:], rest[1]); end >= 0 {
+				usage.members[rest[2:2+end]] = true
+				continue
+			}
+		}
+		usage.wholesale = true
+	}
+}
+
+// expandCSSModuleImports replaces each default import of a CSS module in f with an import per class name that
+// the script uses, so that unused class names can be reported.
+func expandCSSModuleImports(f *File, usages map[string]*memberUsage) {
+	for key, imp := range f.Imports {
+		usage, ok := usages[imp.Name]
+		if !ok || !imp.Default || !isCSSModule(imp.RelPath) {
+			continue
+		}
+		delete(f.Imports, key)
+
+		switch {
+		case usage.wholesale:
+			// We can't tell which class names are used, so all of them are.
+			imp.Namespace = imp.Name
+			f.Imports[imp.Hash(f.RelPath)] = imp
+		case len(usage.members) == 0:
+			// Keep an import without a name, so the module is still visited.
+			imp.Name = ""
+			f.Imports[imp.Hash(f.RelPath)] = imp
+		default:
+			for member := range usage.members {
+				stmt := &ImportStmt{FileRef: f, Line: imp.Line, Name: member, RelPath: imp.RelPath, Local: imp.Local, TopLevel: imp.TopLevel}
+				f.Imports[stmt.Hash(f.RelPath)] = stmt
+			}
+		}
+	}
+}
+
+// isIdentChar returns true if c may be part of an EcmaScript identifier.
+func isIdentChar(c byte) bool {
+	return c == '_' || c == '$' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,126 @@
+package script
+
+import (
+	"strings"
+	"testing"
+
+	set "github.com/deckarep/golang-set"
+)
+
+func TestIsScript(t *testing.T) {
+	cases := map[string]bool{
+		"/path/to/index.js":         true,
+		"/path/to/index.tsx":        true,
+		"/path/to/index":            true,
+		"/path/to/data.json":        false,
+		"/path/to/App.module.css":   false,
+		"/path/to/images/logo.svg":  false,
+		"/path/to/routingLayer.v2":  false,
+		"/path/to/types/index.d.ts": true,
+	}
+
+	for in, expected := range cases {
+		if actual := IsScript(in); actual != expected {
+			t.Fatalf("IsScript(%q): expected %t, got %t", in, expected, actual)
+		}
+	}
+}
+
+func TestParseCSS(t *testing.T) {
+	t.Run("finds class names in CSS modules", func(t *testing.T) {
+		file := `
+/* .commented { color: red; } */
+.button, .button-primary:hover {
+	background: url(./images/bg.png);
+	margin: 0.5em;
+}
+
+@media (max-width: 600px) {
+	.button > .icon {
+		display: none;
+	}
+}
+`
+
+		actual, err := Parse(strings.NewReader(file), "/path/to/App.module.css")
+		if err != nil {
+			t.Fatal(err)
+		}
+		names := make([]interface{}, 0, len(actual.Exports))
+		lines := make(map[string]int, len(actual.Exports))
+		for _, exp := range actual.Exports {
+			names = append(names, exp.Name)
+			lines[exp.Name] = exp.Line
+		}
+		expected := []interface{}{"button", "button-primary", "icon"}
+		if !set.NewSetFromSlice(names).Equal(set.NewSetFromSlice(expected)) {
+			t.Fatalf("Expected %v, got %v", expected, names)
+		}
+		if lines["button"] != 3 || lines["icon"] != 9 {
+			t.Fatalf("Unexpected line numbers: %v", lines)
+		}
+	})
+
+	t.Run("ignores plain stylesheets", func(t *testing.T) {
+		actual, err := Parse(strings.NewReader(".button { color: red; }"), "/path/to/App.css")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(actual.Exports) != 0 {
+			t.Fatalf("Expected no exports, got %d", len(actual.Exports))
+		}
+	})
+}
+
+func TestParseJSON(t *testing.T) {
+	actual, err := Parse(strings.NewReader(`{"name": "esclean"}`), "/path/to/data.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(actual.Exports) != 1 {
+		t.Fatalf("Expected 1 export, got %d", len(actual.Exports))
+	}
+	imp := &ImportStmt{Name: "data", RelPath: "./data.json", Default: true}
+	for _, exp := range actual.Exports {
+		if !exp.Matches(imp) {
+			t.Fatal("Expected the default export to match a default import")
+		}
+	}
+}
+
+func TestParseCSSModuleImports(t *testing.T) {
+	cases := map[string][]interface{}{
+		`
+import styles from './App.module.css'
+
+export const Button = () => <button className={styles.button}>{styles['button-label']}</button>
+`: []interface{}{"button", "button-label"},
+		`
+import styles from './App.module.css'
+
+export const Button = (props) => <button className={cx(styles, props)} />
+`: []interface{}{"styles"},
+		`
+import styles from './App.module.css'
+`: []interface{}{""},
+	}
+
+	for in, expected := range cases {
+		actual, err := Parse(strings.NewReader(in), "/path/to/Button.js")
+		if err != nil {
+			t.Fatal(err)
+		}
+		// Wholesale usage is recorded as a namespaced import.
+		names := make([]interface{}, 0, len(actual.Imports))
+		for _, imp := range actual.Imports {
+			if imp.Namespace != "" {
+				names = append(names, imp.Namespace)
+				continue
+			}
+			names = append(names, imp.Name)
+		}
+		if !set.NewSetFromSlice(names).Equal(set.NewSetFromSlice(expected)) {
+			t.Fatalf("Expected %v, got %v", expected, names)
+		}
+	}
+}
@@ -11,10 +11,12 @@ import (
 // A ExportStmt represents a code definition of which we need to count references to.
 // Examples:
 //   export function sayHello() { ... } -> Name: "sayHello", Signature: "export function sayHello()"
+// Uses contains the local names of the imports that are referred to from within the exported declaration.
 type ExportStmt struct {
 	FileRef         *File
 	Line, RefCount  int
 	Name, Signature string
+	Uses            []string
 	hash            uint64
 }
 
@@ -29,12 +31,28 @@ func (stmt *ExportStmt) Hash(path string) uint64 {
 	return hash
 }
 
+// UsesLocal returns true if the exported declaration refers to the import with the given local name.
+func (stmt *ExportStmt) UsesLocal(local string) bool {
+	for _, use := range stmt.Uses {
+		if use == local {
+			return true
+		}
+	}
+	return false
+}
+
 // Matches returns true if the given ImportStmt matches this ExportStmt.
 // File paths are not checked against each other as this is probably already done
 // as part of the filetree traversal algorithm.
 func (stmt *ExportStmt) Matches(imp *ImportStmt) bool {
+	// Default exports of non-script files, ie. JSON, have no name to match.
+	if stmt.Name == "default" && imp.Default {
+		return true
+	}
+
 	if imp.Namespace == "" {
-		return imp.Name == stmt.Name
+		// Side effect imports, ie. import './polyfill', have no name and match no export.
+		return imp.Name != "" && imp.Name == stmt.Name
 	}
 
 	// For namespaced imports, we need to match <namespace>.<name>.
@@ -47,9 +65,14 @@ func (stmt *ExportStmt) Matches(imp *ImportStmt) bool {
 // Examples:
 //  import * as mystuff from './somewhere' -> Name: "", RelPath: "./", Namespace: "mystuff".
 //  import { myfunc } from './somewhere' -> Name: "myfunc", RelPath: "./", Namespace: "".
+//  import './somewhere' -> Name: "", RelPath: "./", Namespace: "".
 type ImportStmt struct {
 	FileRef                  *File
+	Line                     int
 	Name, RelPath, Namespace string
+	Local                    string // The name that the import is bound to in the importing file.
+	Default                  bool   // True for default imports, ie. import name from './somewhere'.
+	TopLevel                 bool   // True if used outside of any exported declaration.
 	hash                     uint64
 }
 
@@ -69,22 +92,37 @@ func (stmt *ImportStmt) Hash(path string) uint64 {
 }
 
 // A File represents a source file to be analysed.
+// Imports with a bare specifier, ie. a package name or a URL, are kept in BareImports, since they
+// don't refer to a project file unless they are mapped to one.
+// References contains the paths of TypeScript's triple-slash reference directives, and AmbientModules
+// contains the names of the modules declared with "declare module 'name'" blocks.
 type File struct {
-	RelPath string
-	Imports map[uint64]*ImportStmt
-	Exports map[uint64]*ExportStmt
+	RelPath        string
+	Imports        map[uint64]*ImportStmt
+	Exports        map[uint64]*ExportStmt
+	BareImports    map[uint64]*ImportStmt
+	References     []*ImportStmt
+	AmbientModules []string
 }
 
 // NewFile returns a new File with an initialised Statement map.
 func NewFile(relPath string) *File {
 	imports := make(map[uint64]*ImportStmt, 10)
 	exports := make(map[uint64]*ExportStmt, 10)
+	bareImports := make(map[uint64]*ImportStmt)
 
 	f := File{
-		relPath,
-		imports,
-		exports,
+		RelPath:     relPath,
+		Imports:     imports,
+		Exports:     exports,
+		BareImports: bareImports,
 	}
 
 	return &f
 }
+
+// IsBare returns true if the given import path is a bare specifier, ie. a package name or a URL,
+// rather than a relative or absolute path.
+func IsBare(relPath string) bool {
+	return relPath != "" && relPath[:1] != "." && relPath[:1] != "/"
+}
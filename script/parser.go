@@ -4,13 +4,16 @@ import (
 	"bufio"
 	"fmt"
 	"io"
+	"path"
 	"regexp"
+	"sort"
 	"strings"
 )
 
 // Regular expressions.
 var (
 	regexpFunction, regexpVar, regexpDefault, regexpStar *regexp.Regexp
+	regexpReference, regexpAmbient                       *regexp.Regexp
 )
 
 func init() {
@@ -18,6 +21,8 @@ func init() {
 	regexpVar = regexp.MustCompile("(var|let|const) ([a-zA-Z_0-9]*)")
 	regexpDefault = regexp.MustCompile("default ([a-zA-Z_0-9]*)")
 	regexpStar = regexp.MustCompile("\\* as ([a-zA-Z_0-9]*)")
+	regexpReference = regexp.MustCompile(`^///\s*<reference\s+path=["']([^"']+)["']`)
+	regexpAmbient = regexp.MustCompile(`^declare\s+module\s+["']([^"']+)["']`)
 }
 
 type parseMode uint8
@@ -30,15 +35,30 @@ const (
 
 // Parse parses a single EcmaScript6-compatible byte slice and returns a File containing
 // the import and export statements that it could find.
+// Non-script files are parsed as leaf nodes: JSON files have a default export, CSS modules export their
+// class names and any other asset has no exports at all.
 func Parse(r io.Reader, relPath string) (*File, error) {
+	switch ext := path.Ext(relPath); {
+	case ext == ".json":
+		return parseJSON(relPath), nil
+	case styleExts[ext]:
+		return parseCSS(r, relPath)
+	case !IsScript(relPath):
+		return NewFile(relPath), nil
+	}
+
 	var (
 		line, concatStmt       string
 		stmt                   []string
 		mode                   parseMode
 		currLineNr, stmtLineNr int
+		owner                  *ExportStmt // The exported declaration of the current top-level block, if any.
+		inAmbient              bool        // Whether we're in the body of an ambient module declaration.
 		err                    error
 	)
 	f := NewFile(relPath)
+	cssModules := make(map[string]*memberUsage)
+	locals := make(map[string][]*ImportStmt)
 	br := bufio.NewReader(r)
 
 	for {
@@ -46,10 +66,34 @@ func Parse(r io.Reader, relPath string) (*File, error) {
 		if err != nil && err != io.EOF {
 			return f, err
 		}
-		line = strings.Trim(line, " \n")
+		// Declarations are assumed to start at the beginning of a line, and their bodies to be indented.
+		if startsBlock(line) {
+			owner = nil
+		}
+		if strings.HasPrefix(line, "}") {
+			inAmbient = false
+		}
+		// The exports of an ambient module are indented, with tabs as often as with spaces.
+		if inAmbient {
+			line = strings.Trim(line, " \t\r\n")
+		} else {
+			line = strings.Trim(line, " \n")
+		}
 
 		currLineNr++
 
+		// TypeScript's triple-slash reference directives and ambient module declarations, ie.
+		// /// <reference path="./globals.d.ts" /> and declare module 'name' {
+		// These may be indented with tabs, ie. when nested in a declare global block, or end in \r.
+		if directive := strings.TrimSpace(line); mode == modeNop {
+			if matches := regexpReference.FindStringSubmatch(directive); len(matches) > 1 {
+				f.References = append(f.References, &ImportStmt{FileRef: f, Line: currLineNr, RelPath: matches[1]})
+			} else if matches = regexpAmbient.FindStringSubmatch(directive); len(matches) > 1 {
+				f.AmbientModules = append(f.AmbientModules, matches[1])
+				inAmbient = strings.HasSuffix(directive, "{")
+			}
+		}
+
 		if strings.HasPrefix(line, "export") {
 			mode = modeExport
 			stmtLineNr = currLineNr
@@ -58,7 +102,15 @@ func Parse(r io.Reader, relPath string) (*File, error) {
 			stmtLineNr = currLineNr
 		}
 
+		// Track how CSS modules imported by default are used, so we can tell which class names are used.
+		if mode != modeImport {
+			for name, usage := range cssModules {
+				usage.trackMembers(line, name)
+			}
+		}
+
 		// If we have a complete statement, process it and reset the mode.
+		inImport := mode == modeImport
 		if mode > modeNop {
 			stmt = append(stmt, line)
 
@@ -71,7 +123,18 @@ func Parse(r io.Reader, relPath string) (*File, error) {
 
 				for _, imp := range imports {
 					imp.FileRef = f
+					imp.Line = stmtLineNr
+					if imp.Local != "" {
+						locals[imp.Local] = append(locals[imp.Local], imp)
+					}
+					if IsBare(imp.RelPath) {
+						f.BareImports[imp.Hash("")] = imp
+						continue
+					}
 					f.Imports[imp.Hash("")] = imp
+					if imp.Default && isCSSModule(imp.RelPath) {
+						cssModules[imp.Name] = &memberUsage{members: make(map[string]bool)}
+					}
 				}
 
 				mode = modeNop
@@ -82,20 +145,80 @@ func Parse(r io.Reader, relPath string) (*File, error) {
 
 				exp := &ExportStmt{FileRef: f, Line: stmtLineNr, RefCount: 0, Name: findName(concatStmt), Signature: cleanSig(concatStmt)}
 				f.Exports[exp.Hash(relPath)] = exp
+				if exp.Name != "" {
+					owner = exp
+				}
 
 				mode = modeNop
 				stmt = nil
 			}
 		}
 
+		// Track which imports each exported declaration uses. Comments are skipped.
+		if code := strings.TrimLeft(line, "\t"); !inImport && !strings.HasPrefix(code, "//") && !strings.HasPrefix(code, "/*") && !strings.HasPrefix(code, "*") {
+			trackUses(line, owner, locals)
+		}
+
 		// This check is last so we'll be able to read the very last line as well.
 		if err == io.EOF {
 			break
 		}
 	}
+	for _, exp := range f.Exports {
+		sort.Strings(exp.Uses)
+	}
+	expandCSSModuleImports(f, cssModules)
 	return f, nil
 }
 
+// startsBlock returns true if the untrimmed line starts a new top-level block, ie. a declaration or statement
+// that isn't indented. Closing brackets and comments don't start a new block.
+func startsBlock(line string) bool {
+	if line == "" || line[0] == ' ' || line[0] == '\t' || line[0] == '\r' || line[0] == '\n' {
+		return false
+	}
+	return !strings.ContainsAny(line[:1], "})]*") && !strings.HasPrefix(line, "//") && !strings.HasPrefix(line, "/*")
+}
+
+// trackUses records which of the imported local names are used on the given line. They are used by the exported
+// declaration owner, or by top-level code if owner is nil.
+func trackUses(line string, owner *ExportStmt, locals map[string][]*ImportStmt) {
+	for local, imps := range locals {
+		if !containsIdent(line, local) {
+			continue
+		}
+		if owner == nil {
+			for _, imp := range imps {
+				imp.TopLevel = true
+			}
+			continue
+		}
+		if !owner.UsesLocal(local) {
+			owner.Uses = append(owner.Uses, local)
+		}
+	}
+}
+
+// containsIdent returns true if line contains the identifier name, and not just as part of another identifier
+// or as a property name.
+func containsIdent(line, name string) bool {
+	for offset := 0; ; {
+		i := strings.Index(line[offset:], name)
+		if i < 0 {
+			return false
+		}
+		i += offset
+		offset = i + len(name)
+		if i > 0 && (isIdentChar(line[i-1]) || line[i-1] == '.') {
+			continue
+		}
+		if offset < len(line) && isIdentChar(line[offset]) {
+			continue
+		}
+		return true
+	}
+}
+
 // findName attempts to extract the variable/function name from a single line of ES6 code.
 // It can currently handle function definitions along with export names and var, let and const.
 func findName(sig string) string {
@@ -138,11 +261,12 @@ func findImports(sig, fpath string) []*ImportStmt {
 		}
 	}
 	if relPath == "" {
-		return []*ImportStmt{}
-	}
-
-	// Ignore imports from external packages.
-	if relPath[:1] != "." && relPath[:1] != "/" {
+		// import './polyfill' is only imported for its side effects, so there are no names.
+		if words := strings.Fields(sig); len(words) == 2 && words[0] == "import" && isQuoted(words[1]) {
+			stmt := &ImportStmt{RelPath: strings.Trim(words[1], "'\";")}
+			stmt.Hash(fpath)
+			return []*ImportStmt{stmt}
+		}
 		return []*ImportStmt{}
 	}
 
@@ -150,6 +274,7 @@ func findImports(sig, fpath string) []*ImportStmt {
 	matches := regexpStar.FindStringSubmatch(sig)
 	if len(matches) > 0 {
 		stmt := &ImportStmt{Name: "*", RelPath: relPath, Namespace: matches[0][strings.LastIndex(matches[0], " ")+1:]}
+		stmt.Local = stmt.Namespace
 		stmt.Hash(fpath)
 		return []*ImportStmt{stmt}
 	}
@@ -161,7 +286,7 @@ func findImports(sig, fpath string) []*ImportStmt {
 		if len(words) < 4 {
 			panic(fmt.Sprintf("unreadable import statement: %q", sig))
 		}
-		stmt := &ImportStmt{Name: words[1], RelPath: relPath, Namespace: ""}
+		stmt := &ImportStmt{Name: words[1], RelPath: relPath, Namespace: "", Local: words[1], Default: true}
 		stmt.Hash(fpath)
 		return []*ImportStmt{stmt}
 	}
@@ -182,7 +307,12 @@ func findImports(sig, fpath string) []*ImportStmt {
 		if end == -1 {
 			end = len(part)
 		}
+		// import { a as b } binds a to the local name b.
+		words := strings.Fields(part)
 		imps[k] = &ImportStmt{Name: part[:end], RelPath: relPath, Namespace: ""}
+		if len(words) > 0 {
+			imps[k].Local = words[len(words)-1]
+		}
 		imps[k].Hash(fpath)
 	}
 
@@ -203,3 +333,9 @@ func cleanSig(sig string) string {
 	}
 	return strings.Trim(cleaned, " {\n")
 }
+
+// isQuoted returns true if s is a string literal, ie. './polyfill' or "./polyfill".
+func isQuoted(s string) bool {
+	s = strings.TrimRight(s, ";")
+	return len(s) > 1 && (s[0] == '\'' || s[0] == '"') && s[len(s)-1] == s[0]
+}
@@ -2,6 +2,7 @@ package script
 
 import (
 	"io"
+	"reflect"
 	"strings"
 	"testing"
 
@@ -85,6 +86,8 @@ func TestFindImportNamesFindsNames(t *testing.T) {
 		"import {aa as bb} from './somewhere/else'":                               []interface{}{"aa"},
 		"import { aa as name1, bb as name2, cc as NAME3} from './somewhere/else'": []interface{}{"aa", "bb", "cc"},
 		"import superRoutes from './my/routingLayer.v2'":                          []interface{}{"superRoutes"},
+		"import './polyfill'":                                                     []interface{}{""},
+		"import \"./styles/App.css\";":                                            []interface{}{""},
 	}
 
 	for in, expected := range cases {
@@ -95,7 +98,7 @@ func TestFindImportNamesFindsNames(t *testing.T) {
 		}
 		actualSet := set.NewSetFromSlice(actualIf)
 		if !actualSet.Equal(set.NewSetFromSlice(expected)) {
-			t.Fatalf("Expected %q, got %q", expected, actual)
+			t.Fatalf("Expected %q, got %q", expected, actualIf)
 		}
 	}
 }
@@ -122,7 +125,7 @@ func TestFindImportsFindsNamespaces(t *testing.T) {
 		}
 		actualSet := set.NewSetFromSlice(actualIf)
 		if !actualSet.Equal(set.NewSetFromSlice(expected)) {
-			t.Fatalf("Expected %q, got %q", expected, actual)
+			t.Fatalf("Expected %q, got %q", expected, actualIf)
 		}
 	}
 }
@@ -280,6 +283,89 @@ function ignoredFunction() {
 		}
 	})
 
+	t.Run("keeps bare imports apart", func(t *testing.T) {
+		file := `
+import React from 'react'
+import { firstName } from './somewhere'
+import { map } from "lodash/fp"
+`
+
+		r := strings.NewReader(file)
+		actual, err := Parse(r, "./")
+
+		if err != nil && err != io.EOF {
+			t.Error(err)
+		}
+		if len(actual.Imports) != 1 {
+			t.Fatalf("Expected len(actual.Imports) == 1, actual == %d", len(actual.Imports))
+		}
+		if len(actual.BareImports) != 2 {
+			t.Fatalf("Expected len(actual.BareImports) == 2, actual == %d", len(actual.BareImports))
+		}
+		if !importStmtContainsAll(actual.BareImports, []string{"React", "map"}) {
+			t.Fatalf("Expected actual.BareImports to contain %v", []string{"React", "map"})
+		}
+	})
+
+	t.Run("finds reference directives and ambient modules", func(t *testing.T) {
+		file := `
+/// <reference path="./globals.d.ts" />
+/// <reference types="node" />
+
+declare module 'analytics' {
+	export function track(event: string): void;
+}
+
+declare module "*.svg" {
+	const content: string;
+	export default content;
+}
+`
+
+		r := strings.NewReader(file)
+		actual, err := Parse(r, "./types.d.ts")
+
+		if err != nil && err != io.EOF {
+			t.Error(err)
+		}
+		if len(actual.References) != 1 || actual.References[0].RelPath != "./globals.d.ts" || actual.References[0].Line != 2 {
+			t.Fatalf("Expected a reference to ./globals.d.ts on line 2, got %v", actual.References)
+		}
+		if len(actual.AmbientModules) != 2 || actual.AmbientModules[0] != "analytics" || actual.AmbientModules[1] != "*.svg" {
+			t.Fatalf("Expected ambient modules analytics and *.svg, got %v", actual.AmbientModules)
+		}
+		if !exportStmtContainsAll(actual.Exports, []string{"track"}) {
+			t.Fatalf("Expected actual.Exports to contain %v", []string{"track"})
+		}
+	})
+
+	t.Run("only trims tabs in ambient module declarations", func(t *testing.T) {
+		file := "/// <reference path=\"./globals.d.ts\" />\r\n" +
+			"namespace internal {\n" +
+			"\texport const hidden = 1;\n" +
+			"}\n" +
+			"declare module 'analytics' {\r\n" +
+			"\texport function track(event: string): void;\r\n" +
+			"}\r\n" +
+			"\texport const indented = 2;\n"
+
+		r := strings.NewReader(file)
+		actual, err := Parse(r, "./types.d.ts")
+
+		if err != nil && err != io.EOF {
+			t.Error(err)
+		}
+		if len(actual.References) != 1 || actual.References[0].RelPath != "./globals.d.ts" {
+			t.Fatalf("Expected a reference to ./globals.d.ts, got %v", actual.References)
+		}
+		if len(actual.AmbientModules) != 1 || actual.AmbientModules[0] != "analytics" {
+			t.Fatalf("Expected ambient module analytics, got %v", actual.AmbientModules)
+		}
+		if len(actual.Exports) != 1 || !exportStmtContainsAll(actual.Exports, []string{"track"}) {
+			t.Fatalf("Expected actual.Exports to contain only %v, got %d exports", []string{"track"}, len(actual.Exports))
+		}
+	})
+
 	t.Run("finds exported names in the last line", func(t *testing.T) {
 		file := `
 export const name = 'Martin';`
@@ -298,4 +384,42 @@ export const name = 'Martin';`
 			t.Fatalf("Expected actual.Exports to contain %v", expected)
 		}
 	})
+	t.Run("finds the imports used by each export", func(t *testing.T) {
+		file := `
+import { format } from './format'
+import { parse as parseDate } from './date'
+import { log } from './log'
+import { unused } from './unused'
+
+function helper(value) {
+    log(value)
+}
+
+export function formatDate(value) {
+    return format(parseDate(value))
+}
+
+// The log function is used by the helper above.
+export const name = 'Martin';
+`
+
+		r := strings.NewReader(file)
+		actual, err := Parse(r, "./")
+
+		if err != nil && err != io.EOF {
+			t.Error(err)
+		}
+		for _, exp := range actual.Exports {
+			expected := map[string][]string{"formatDate": {"format", "parseDate"}, "name": nil}[exp.Name]
+			if !reflect.DeepEqual(exp.Uses, expected) {
+				t.Fatalf("Expected %s to use %v, got %v", exp.Name, expected, exp.Uses)
+			}
+		}
+		topLevel := map[string]bool{"format": false, "parseDate": false, "log": true, "unused": false}
+		for _, imp := range actual.Imports {
+			if imp.TopLevel != topLevel[imp.Local] {
+				t.Fatalf("Expected TopLevel of %s to be %t, got %t", imp.Local, topLevel[imp.Local], imp.TopLevel)
+			}
+		}
+	})
 }